@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"social-media-app/internal/config"
+)
+
+// runConfigCommand handles "config print [--redacted]", which writes the
+// configuration the server would start with as YAML, and returns the exit code
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: config print [--redacted] [flags]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	redacted := fs.Bool("redacted", false, "replace passwords and client secrets")
+	flags := config.RegisterFlags(fs)
+	fs.Parse(args[1:])
+
+	cfg, err := config.Load(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *redacted {
+		cfg = cfg.Redacted()
+	}
+
+	out, err := cfg.YAML()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"social-media-app/internal/models"
+	"social-media-app/internal/services"
+	"social-media-app/internal/utils"
+)
+
+var adminService *services.AdminService
+
+// InitAdminHandler initializes the admin handler with the provided service
+func InitAdminHandler(service *services.AdminService) {
+	adminService = service
+}
+
+func AdminListUsers(c *gin.Context) {
+	limit, offset := parseLimitOffset(c)
+	users, err := adminService.ListUsers(c.Request.Context(), limit, offset)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Users retrieved successfully", users)
+}
+
+func AdminSuspendUser(c *gin.Context) {
+	setUserActive(c, false, "User suspended successfully")
+}
+
+func AdminUnsuspendUser(c *gin.Context) {
+	setUserActive(c, true, "User unsuspended successfully")
+}
+
+func setUserActive(c *gin.Context, active bool, message string) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := adminService.SetUserActive(c.Request.Context(), requestMeta(c), uint(userID), active)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, message, user)
+}
+
+func AdminUpdateUserRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	user, err := adminService.SetUserRole(c.Request.Context(), requestMeta(c), uint(userID), req.Role)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User role updated successfully", user)
+}
+
+func AdminGetUserRateLimits(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	limits, err := adminService.GetRateLimits(c.Request.Context(), uint(userID))
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Rate limits retrieved successfully", limits)
+}
+
+// AdminResetUserRateLimits clears the counters for the action given in the
+// query string, or for every action if none is given
+func AdminResetUserRateLimits(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := adminService.ResetRateLimits(c.Request.Context(), requestMeta(c), uint(userID), c.Query("action")); err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Rate limits reset successfully", nil)
+}
+
+func AdminDeletePost(c *gin.Context) {
+	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	if err := adminService.DeletePost(c.Request.Context(), requestMeta(c), uint(postID)); err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Post deleted successfully", nil)
+}
+
+// AdminListAuditLogs supports filtering by actor_id, action, target_type,
+// target_id, request_id and an RFC 3339 from/to time range
+func AdminListAuditLogs(c *gin.Context) {
+	var filter models.AuditLogFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	limit, offset := parseLimitOffset(c)
+	entries, err := adminService.ListAuditLogs(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Audit log retrieved successfully", entries)
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -20,13 +23,13 @@ func Register(c *gin.Context) {
 	var req models.RegisterRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input data")
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
-	response, err := authService.Register(req)
+	response, err := authService.Register(c.Request.Context(), requestMeta(c), req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -36,19 +39,41 @@ func Login(c *gin.Context) {
 	var req models.LoginRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
-	response, err := authService.Login(req)
+	response, err := authService.Login(c.Request.Context(), requestMeta(c), req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		loginErrorResponse(c, err)
+		return
+	}
+
+	if response.RequiresTwoFactor {
+		utils.SuccessResponse(c, http.StatusOK, "Two-factor authentication required", response)
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "User logged in successfully", response)
 }
 
+// loginErrorResponse reports lockouts as 429 with Retry-After, and a 503 when
+// login attempts can't be counted
+func loginErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrLoginUnavailable) {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Login is temporarily unavailable, try again later")
+		return
+	}
+
+	var lockedErr *services.AccountLockedError
+	if errors.As(err, &lockedErr) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+		utils.ErrorResponseWithCode(c, http.StatusTooManyRequests, utils.ErrCodeAccountLocked, err.Error())
+		return
+	}
+	utils.ServiceErrorResponse(c, err)
+}
+
 func Logout(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "User logged out successfully", gin.H{
 		"message": "Please remove the token from client storage",
@@ -26,13 +26,13 @@ func FollowUser(c *gin.Context) {
 
 	var followRequest models.FollowRequest
 	if err := c.ShouldBindJSON(&followRequest); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request: "+err.Error())
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
-	err := followService.FollowUser(userID.(uint), followRequest.UserID)
+	err := followService.FollowUser(c.Request.Context(), requestMeta(c), userID.(uint), followRequest.UserID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -53,9 +53,9 @@ func UnfollowUser(c *gin.Context) {
 		return
 	}
 
-	err = followService.UnfollowUser(userID.(uint), uint(targetID))
+	err = followService.UnfollowUser(c.Request.Context(), requestMeta(c), userID.(uint), uint(targetID))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -70,9 +70,10 @@ func GetFollowers(c *gin.Context) {
 		return
 	}
 
-	followers, err := followService.GetFollowers(uint(userID))
+	limit, offset := parseLimitOffset(c)
+	followers, err := followService.GetFollowers(c.Request.Context(), c.GetUint("user_id"), uint(userID), limit, offset)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -87,9 +88,10 @@ func GetFollowing(c *gin.Context) {
 		return
 	}
 
-	following, err := followService.GetFollowing(uint(userID))
+	limit, offset := parseLimitOffset(c)
+	following, err := followService.GetFollowing(c.Request.Context(), c.GetUint("user_id"), uint(userID), limit, offset)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
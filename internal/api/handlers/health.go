@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"social-media-app/internal/health"
+)
+
+var healthChecker *health.Checker
+
+func InitHealthHandler(checker *health.Checker) {
+	healthChecker = checker
+}
+
+// Livez reports that the process is running. It does not check dependencies,
+// so an outage elsewhere does not get the API restarted.
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports whether the API can serve traffic, with the status and
+// latency of each dependency
+func Readyz(c *gin.Context) {
+	report, ready := healthChecker.Ready(c.Request.Context())
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
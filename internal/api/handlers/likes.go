@@ -20,7 +20,7 @@ func InitLikeHandler(s *services.LikeService) {
 func LikePost(c *gin.Context) {
 	var likeRequest models.LikeRequest
 	if err := c.ShouldBindJSON(&likeRequest); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
@@ -32,31 +32,19 @@ func LikePost(c *gin.Context) {
 
 	likedBy := userID.(uint)
 
-	// Check if already liked
-	isLiked, err := like_service.IsPostLikedByUser(likeRequest.PostID, likedBy)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Error checking like status")
-		return
-	}
-
-	if isLiked {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Post already liked by this user")
-		return
-	}
-
-	if err := like_service.LikePost(likedBy, likeRequest.PostID); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	if err := like_service.LikePost(c.Request.Context(), likedBy, likeRequest.PostID); err != nil {
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
 	// Get updated like count
-	likeCount, err := like_service.GetLikeCount(likeRequest.PostID)
+	likeCount, err := like_service.GetLikeCount(c.Request.Context(), likeRequest.PostID)
 	if err != nil {
 		likeCount = 0
 	}
 
 	// Get list of users who liked this post
-	likedUserIDs, err := like_service.GetLikedUserIDs(likeRequest.PostID)
+	likedUserIDs, err := like_service.GetLikedUserIDs(c.Request.Context(), likeRequest.PostID)
 	if err != nil {
 		likedUserIDs = []uint{}
 	}
@@ -85,32 +73,20 @@ func UnlikePost(c *gin.Context) {
 
 	likedBy := userID.(uint)
 
-	// Check if actually liked
-	isLiked, err := like_service.IsPostLikedByUser(uint(postID), likedBy)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Error checking like status")
-		return
-	}
-
-	if !isLiked {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Post not liked by this user")
-		return
-	}
-
-	err = like_service.UnlikePost(likedBy, uint(postID))
+	err = like_service.UnlikePost(c.Request.Context(), likedBy, uint(postID))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
 	// Get updated like count
-	likeCount, err := like_service.GetLikeCount(uint(postID))
+	likeCount, err := like_service.GetLikeCount(c.Request.Context(), uint(postID))
 	if err != nil {
 		likeCount = 0
 	}
 
 	// Get list of users who liked this post
-	likedUserIDs, err := like_service.GetLikedUserIDs(uint(postID))
+	likedUserIDs, err := like_service.GetLikedUserIDs(c.Request.Context(), uint(postID))
 	if err != nil {
 		likedUserIDs = []uint{}
 	}
@@ -132,9 +108,9 @@ func GetPostLikes(c *gin.Context) {
 		return
 	}
 
-	likes, err := like_service.GetPostLikes(uint(postID))
+	likes, err := like_service.GetPostLikes(c.Request.Context(), uint(postID))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Error fetching likes")
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"social-media-app/internal/logging"
+	"social-media-app/internal/models"
+	"social-media-app/internal/services"
+	"social-media-app/internal/utils"
+)
+
+var oauthService *services.OAuthService
+
+// InitOAuthHandler initializes the OAuth handler with the provided service
+func InitOAuthHandler(service *services.OAuthService) {
+	oauthService = service
+}
+
+// OAuthLogin redirects the user to the provider's authorization page
+func OAuthLogin(c *gin.Context) {
+	authURL, err := oauthService.AuthorizationURL(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownOAuthProvider) {
+			utils.ServiceErrorResponse(c, err)
+			return
+		}
+		// Discovery against the provider failed
+		logging.FromContext(c.Request.Context()).Error("OAuth login failed", "error", err)
+		utils.ErrorResponse(c, http.StatusBadGateway, "Failed to reach the OAuth provider")
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OAuthCallback completes the login once the provider redirects back
+func OAuthCallback(c *gin.Context) {
+	var req models.OAuthCallbackRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	response, err := oauthService.HandleCallback(c.Request.Context(), requestMeta(c), c.Param("provider"), req)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	if response.RequiresTwoFactor {
+		utils.SuccessResponse(c, http.StatusOK, "Two-factor authentication required", response)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User logged in successfully", response)
+}
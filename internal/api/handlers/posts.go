@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -17,6 +18,9 @@ func InitPostHandler(service *services.PostService) {
 	postService = service
 }
 
+// maxPageSize caps the limit a client can ask for in one page
+const maxPageSize = 100
+
 func parseLimitOffset(c *gin.Context) (int, int) {
 	limitStr := c.DefaultQuery("limit", "10")
 	offsetStr := c.DefaultQuery("offset", "0")
@@ -25,6 +29,9 @@ func parseLimitOffset(c *gin.Context) (int, int) {
 	if err != nil || limit <= 0 {
 		limit = 10
 	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil || offset < 0 {
 		offset = 0
@@ -33,16 +40,16 @@ func parseLimitOffset(c *gin.Context) (int, int) {
 }
 
 func GetPosts(c *gin.Context) {
-	_, exists := c.Get("user_id")
+	userID, exists := c.Get("user_id")
 	if !exists {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	limit, offset := parseLimitOffset(c)
-	posts, err := postService.GetAll(limit, offset)
+	posts, err := postService.GetAll(c.Request.Context(), userID.(uint), limit, offset)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -57,18 +64,24 @@ func CreatePost(c *gin.Context) {
 	}
 
 	var post models.CreatePostRequest
-	if err := c.BindJSON(&post); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body: "+err.Error())
+	if err := c.ShouldBindJSON(&post); err != nil {
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
-	err := postService.CreatePost(userID.(uint), post.Content, post.ImageURL)
+	created, err := postService.CreatePost(c.Request.Context(), userID.(uint), post.Content, post.ImageURL)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusCreated, "Post created successfully", nil)
+	c.Header("Location", postLocation(created.ID))
+	if created.IsHidden {
+		utils.SuccessResponse(c, http.StatusCreated, "Post created and held for review", created)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Post created successfully", created)
 }
 
 func GetPostByID(c *gin.Context) {
@@ -79,9 +92,9 @@ func GetPostByID(c *gin.Context) {
 		return
 	}
 
-	post, err := postService.GetByID(uint(postID))
+	post, err := postService.GetByID(c.Request.Context(), c.GetUint("user_id"), uint(postID))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "Post not found")
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -89,6 +102,12 @@ func GetPostByID(c *gin.Context) {
 }
 
 func UpdatePost(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
 	postIDParam := c.Param("id")
 	postID, err := strconv.ParseUint(postIDParam, 10, 32)
 	if err != nil {
@@ -97,18 +116,24 @@ func UpdatePost(c *gin.Context) {
 	}
 
 	var post models.UpdatePostRequest
-	if err := c.BindJSON(&post); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+	if err := c.ShouldBindJSON(&post); err != nil {
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
-	err = postService.Update(&models.Post{ID: uint(postID), Content: post.Content, ImageURL: post.ImageURL})
+	updated, err := postService.Update(c.Request.Context(), userID.(uint), uint(postID), post.Content, post.ImageURL)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Content-Location", postLocation(updated.ID))
+	if updated.IsHidden {
+		utils.SuccessResponse(c, http.StatusOK, "Post updated and held for review", updated)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Post updated successfully", nil)
+	utils.SuccessResponse(c, http.StatusOK, "Post updated successfully", updated)
 }
 
 func DeletePost(c *gin.Context) {
@@ -119,9 +144,9 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
-	err = postService.Delete(uint(postID))
+	err = postService.DeleteOwnPost(c.Request.Context(), requestMeta(c), uint(postID))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -137,9 +162,9 @@ func GetUserPosts(c *gin.Context) {
 	}
 
 	limit, offset := parseLimitOffset(c)
-	posts, err := postService.GetByUserID(uint(userID), limit, offset)
+	posts, err := postService.GetByUserID(c.Request.Context(), c.GetUint("user_id"), uint(userID), limit, offset)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -154,11 +179,15 @@ func GetTimeline(c *gin.Context) {
 	}
 
 	limit, offset := parseLimitOffset(c)
-	posts, err := postService.GetTimeline(userID.(uint), limit, offset)
+	posts, err := postService.GetTimeline(c.Request.Context(), userID.(uint), limit, offset)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Timeline retrieved successfully", posts)
 }
+
+func postLocation(postID uint) string {
+	return fmt.Sprintf("/api/posts/%d", postID)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"social-media-app/internal/models"
+	"social-media-app/internal/services"
+	"social-media-app/internal/utils"
+)
+
+var reportService *services.ReportService
+
+// InitReportHandler initializes the report handler with the provided service
+func InitReportHandler(service *services.ReportService) {
+	reportService = service
+}
+
+func CreateReport(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req models.CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	report, err := reportService.CreateReport(c.Request.Context(), requestMeta(c), userID.(uint), req)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Report submitted successfully", report)
+}
+
+// ListReports returns the moderation queue, filtered by status, target_type,
+// assignee_id or unassigned=true
+func ListReports(c *gin.Context) {
+	var filter models.ReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	limit, offset := parseLimitOffset(c)
+	reports, err := reportService.ListReports(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reports retrieved successfully", reports)
+}
+
+func AssignReport(c *gin.Context) {
+	reportID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	var req models.AssignReportRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BindingErrorResponse(c, err)
+			return
+		}
+	}
+
+	report, err := reportService.AssignReport(c.Request.Context(), requestMeta(c), uint(reportID), req.AssigneeID)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Report assigned successfully", report)
+}
+
+func ResolveReport(c *gin.Context) {
+	reportID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	var req models.ResolveReportRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BindingErrorResponse(c, err)
+			return
+		}
+	}
+
+	report, err := reportService.ResolveReport(c.Request.Context(), requestMeta(c), uint(reportID), req)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Report resolved successfully", report)
+}
+
+func DismissReport(c *gin.Context) {
+	reportID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	var req models.DismissReportRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BindingErrorResponse(c, err)
+			return
+		}
+	}
+
+	report, err := reportService.DismissReport(c.Request.Context(), requestMeta(c), uint(reportID), req)
+	if err != nil {
+		utils.ServiceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Report dismissed successfully", report)
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"social-media-app/internal/models"
+)
+
+// requestMeta collects who is making the request and from where, for auditing
+func requestMeta(c *gin.Context) models.RequestMeta {
+	meta := models.RequestMeta{
+		ClientIP:  c.ClientIP(),
+		RequestID: c.GetString("request_id"),
+	}
+	if userID, exists := c.Get("user_id"); exists {
+		meta.ActorID = userID.(uint)
+	}
+	return meta
+}
@@ -25,9 +25,9 @@ func GetProfile(c *gin.Context) {
 		return
 	}
 
-	profile, err := userService.GetProfile(userID.(uint))
+	profile, err := userService.GetProfile(c.Request.Context(), userID.(uint))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -43,13 +43,13 @@ func UpdateProfile(c *gin.Context) {
 
 	var req models.UpdateProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input data")
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
-	profile, err := userService.UpdateProfile(userID.(uint), req)
+	profile, err := userService.UpdateProfile(c.Request.Context(), requestMeta(c), userID.(uint), req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -64,9 +64,9 @@ func GetUserByID(c *gin.Context) {
 		return
 	}
 
-	profile, err := userService.GetUserByID(uint(userID))
+	profile, err := userService.GetUserByID(c.Request.Context(), c.GetUint("user_id"), uint(userID))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
@@ -98,9 +98,9 @@ func SearchUsers(c *gin.Context) {
 		offset = 0
 	}
 
-	users, err := userService.SearchUsers(query, limit, offset)
+	users, err := userService.SearchUsers(c.Request.Context(), query, limit, offset)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to search users")
+		utils.ServiceErrorResponse(c, err)
 		return
 	}
 
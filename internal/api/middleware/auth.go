@@ -1,16 +1,22 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
-	"social-media-app/internal/config"
+	"social-media-app/internal/models"
 	"social-media-app/internal/utils"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// UserLookup loads the current state of an authenticated user
+type UserLookup interface {
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+}
+
+func AuthMiddleware(keys *utils.KeySet, users UserLookup) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -32,17 +38,75 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		cfg := config.Load()
-		claims, err := utils.ValidateToken(tokenString, cfg.JWT.Secret)
+		claims, err := utils.ValidateToken(tokenString, keys)
 		if err != nil {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
 			c.Abort()
 			return
 		}
 
+		// Look the user up so suspensions and role changes apply to existing tokens
+		user, err := users.GetByID(c.Request.Context(), claims.UserID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
+			c.Abort()
+			return
+		}
+		if !user.IsActive {
+			utils.ErrorResponse(c, http.StatusForbidden, "Account is suspended")
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
+		c.Set("role", user.Role)
+		withLogAttrs(c, "user_id", claims.UserID)
+
+		c.Next()
+	}
+}
+
+// RequireRole only lets users with one of the given roles through.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+			c.Abort()
+			return
+		}
+
+		for _, allowed := range roles {
+			if role.(models.Role) == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions")
+		c.Abort()
+	}
+}
+
+// RequirePermission only lets users whose role grants the permission through.
+// It must run after AuthMiddleware.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+			c.Abort()
+			return
+		}
+
+		if !role.(models.Role).HasPermission(permission) {
+			utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions")
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"social-media-app/internal/logging"
+	"social-media-app/internal/models"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/utils"
+)
+
+const (
+	idempotencyHeader       = "Idempotency-Key"
+	idempotencyMaxKeyLength = 255
+	// A request still in progress after this long is assumed to have died,
+	// so a retry is allowed to run again
+	idempotencyLockTimeout = time.Minute
+)
+
+// Headers of the original response that are replayed
+var idempotencyReplayHeaders = []string{"Content-Type", "Location"}
+
+// idempotencyWriter keeps a copy of the response body so it can be stored
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency stores the first response to a write request carrying an
+// Idempotency-Key header and replays it for retries with the same key, so a
+// client that timed out can safely send the request again. Keys are scoped
+// to the user, method and path. Reusing a key with a different body is
+// rejected. Must run after AuthMiddleware.
+func Idempotency(repo repository.IdempotencyRepository, expiry time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(idempotencyHeader)
+		if idempotencyKey == "" || !isWriteMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > idempotencyMaxKeyLength {
+			utils.ErrorResponse(c, http.StatusBadRequest,
+				fmt.Sprintf("%s must be at most %d characters", idempotencyHeader, idempotencyMaxKeyLength))
+			c.Abort()
+			return
+		}
+
+		userID, exists := c.Get("user_id")
+		if !exists {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read request body")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(hash[:])
+		key := fmt.Sprintf("%d:%s %s:%s", userID.(uint), c.Request.Method, c.Request.URL.Path, idempotencyKey)
+
+		existing, err := repo.Reserve(c.Request.Context(), key, models.IdempotencyRecord{
+			Status:      models.IdempotencyInProgress,
+			RequestHash: requestHash,
+		}, idempotencyLockTimeout)
+		if err != nil {
+			// Without Redis the request runs unprotected rather than failing
+			logging.FromContext(c.Request.Context()).Warn("Idempotency check failed, continuing without it", "error", err)
+			c.Next()
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.RequestHash != requestHash:
+				utils.ErrorResponse(c, http.StatusUnprocessableEntity,
+					idempotencyHeader+" was already used with a different request body")
+			case existing.Status == models.IdempotencyInProgress:
+				utils.ErrorResponse(c, http.StatusConflict,
+					"A request with this "+idempotencyHeader+" is still being processed")
+			default:
+				for name, value := range existing.Headers {
+					c.Header(name, value)
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, existing.Headers["Content-Type"], existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		// The response is stored after it has been sent, even if the client is gone by then
+		storeCtx := context.WithoutCancel(c.Request.Context())
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		// Release the key if the handler panics so the request can be retried
+		completed := false
+		defer func() {
+			if !completed {
+				if err := repo.Delete(storeCtx, key); err != nil {
+					logging.FromContext(storeCtx).Error("Failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		// Server errors and rate limiting are transient, so let the client retry
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			return
+		}
+
+		record := models.IdempotencyRecord{
+			Status:      models.IdempotencyCompleted,
+			RequestHash: requestHash,
+			StatusCode:  status,
+			Headers:     make(map[string]string),
+			Body:        writer.body.Bytes(),
+		}
+		for _, name := range idempotencyReplayHeaders {
+			if value := writer.Header().Get(name); value != "" {
+				record.Headers[name] = value
+			}
+		}
+
+		if err := repo.Save(storeCtx, key, record, expiry); err != nil {
+			logging.FromContext(storeCtx).Error("Failed to store idempotent response", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+func isWriteMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
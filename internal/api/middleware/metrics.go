@@ -7,6 +7,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"social-media-app/internal/tracing"
 )
 
 var (
@@ -36,15 +38,6 @@ var (
 			Help: "Number of active HTTP requests",
 		},
 	)
-
-	// Counter for requests per minute (calculated in Grafana)
-	httpRequestsPerMinute = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_per_minute_total",
-			Help: "Total HTTP requests for rate calculation",
-		},
-		[]string{"endpoint"},
-	)
 )
 
 // collects HTTP metrics
@@ -66,9 +59,19 @@ func PrometheusMiddleware() gin.HandlerFunc {
 
 		// Record metrics
 		httpRequestsTotal.WithLabelValues(method, endpoint, status).Inc()
-		httpRequestDuration.WithLabelValues(method, endpoint).Observe(duration)
-		httpRequestsPerMinute.WithLabelValues(endpoint).Inc()
+		observeWithTraceID(c, httpRequestDuration.WithLabelValues(method, endpoint), duration)
+	}
+}
+
+// observeWithTraceID links the observation to the request's trace as an exemplar
+func observeWithTraceID(c *gin.Context, observer prometheus.Observer, value float64) {
+	traceID := tracing.TraceID(c.Request.Context())
+	exemplarObserver, ok := observer.(prometheus.ExemplarObserver)
+	if traceID == "" || !ok {
+		observer.Observe(value)
+		return
 	}
+	exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{"trace_id": traceID})
 }
 
 // getCleanPath removes path parameters for cleaner metrics
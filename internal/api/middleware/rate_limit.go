@@ -1,41 +1,103 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
-	"golang.org/x/net/context"
 
 	"social-media-app/internal/config"
+	"social-media-app/internal/database"
+	"social-media-app/internal/logging"
+	"social-media-app/internal/metrics"
+	"social-media-app/internal/models"
+	"social-media-app/internal/ratelimit"
 	"social-media-app/internal/utils"
 )
 
 type RateLimiter struct {
-	client *redis.Client
-	cfg    *config.RateLimitConfig
-	ctx    context.Context
+	client   *redis.Client
+	policies *ratelimit.Policies
+	store    ratelimit.Store
+	fallback ratelimit.Store
+
+	// Actions with a rate limited route, reported by UserLimits
+	mu      sync.Mutex
+	actions map[string]struct{}
 }
 
-func NewRateLimiter(cfg *config.Config) *RateLimiter {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Host + ":" + cfg.Redis.Port,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
+// NewRateLimiter loads the rate limit policies and watches the policy file,
+// if one is configured, for changes until ctx is done
+func NewRateLimiter(ctx context.Context, cfg *config.Config) (*RateLimiter, error) {
+	policies, err := ratelimit.NewPolicies(defaultPolicies(cfg.RateLimit), cfg.RateLimit.PolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rate limit policies: %w", err)
+	}
+	go policies.Watch(ctx, cfg.RateLimit.PolicyReloadInterval)
+
+	client := database.NewRedisClient(cfg, "rate_limit")
 
 	return &RateLimiter{
-		client: client,
-		cfg:    &cfg.RateLimit,
-		ctx:    context.Background(),
+		client:   client,
+		policies: policies,
+		store:    ratelimit.NewRedisStore(client),
+		fallback: ratelimit.NewLocalStore(),
+		actions:  make(map[string]struct{}),
+	}, nil
+}
+
+// defaultPolicies are the limits used for anything the policy file leaves out
+func defaultPolicies(cfg config.RateLimitConfig) *ratelimit.PolicySet {
+	hourly := func(requests int) ratelimit.Policy {
+		return ratelimit.Policy{Requests: requests, Window: time.Hour}
+	}
+
+	set := &ratelimit.PolicySet{
+		Default: ratelimit.Policy{
+			Requests:      cfg.Requests,
+			Window:        cfg.Window,
+			Algorithm:     cfg.Algorithm,
+			FailurePolicy: cfg.FailurePolicy,
+		},
+		Actions: map[string]ratelimit.Policy{
+			"register":       hourly(10),
+			"login":          hourly(10),
+			"verify_2fa":     hourly(10),
+			"manage_2fa":     hourly(10),
+			"oauth_login":    hourly(10),
+			"oauth_callback": hourly(10),
+			"create_post":    hourly(20),
+			"like_post":      hourly(60),
+			"unlike_post":    hourly(60),
+			"follow_user":    hourly(50),
+			"unfollow_user":  hourly(50),
+			"create_report":  hourly(20),
+		},
+	}
+
+	for action, failurePolicy := range cfg.ActionFailurePolicies {
+		policy := set.Actions[action]
+		policy.FailurePolicy = failurePolicy
+		set.Actions[action] = policy
 	}
+
+	return set
+}
+
+// Ping checks that the rate limit store can be reached
+func (rl *RateLimiter) Ping(ctx context.Context) error {
+	return rl.client.Ping(ctx).Err()
 }
 
 // Rate limit based on authenticated user ID
 func (rl *RateLimiter) RateLimitByUser(action string) gin.HandlerFunc {
+	rl.register(action)
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -44,183 +106,186 @@ func (rl *RateLimiter) RateLimitByUser(action string) gin.HandlerFunc {
 			return
 		}
 
-		key := fmt.Sprintf("rate_limit:user:%d:%s", userID.(uint), action)
-
-		if allowed, resetTime, err := rl.checkRateLimit(key); err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Rate limiting error")
-			c.Abort()
-			return
-		} else if !allowed {
-			c.Header("X-RateLimit-Limit", strconv.Itoa(rl.cfg.Requests))
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(resetTime, 10))
-			utils.ErrorResponse(c, http.StatusTooManyRequests,
-				fmt.Sprintf("Rate limit exceeded for %s. Try again later.", action))
-			c.Abort()
-			return
-		}
-
-		c.Next()
+		rl.enforce(c, action, userKey(userID.(uint), action))
 	}
 }
 
 // Rate limit based on IP address
 func (rl *RateLimiter) RateLimitByIP(action string) gin.HandlerFunc {
+	rl.register(action)
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-		key := fmt.Sprintf("rate_limit:ip:%s:%s", clientIP, action)
-
-		if allowed, resetTime, err := rl.checkRateLimit(key); err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Rate limiting error")
-			c.Abort()
-			return
-		} else if !allowed {
-			c.Header("X-RateLimit-Limit", strconv.Itoa(rl.cfg.Requests))
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(resetTime, 10))
-			utils.ErrorResponse(c, http.StatusTooManyRequests,
-				fmt.Sprintf("Rate limit exceeded for %s. Try again later.", action))
-			c.Abort()
-			return
-		}
-
-		c.Next()
+		rl.enforce(c, action, ipKey(c.ClientIP(), action))
 	}
 }
 
 // Rate limit by user if authenticated, otherwise by IP
 func (rl *RateLimiter) RateLimitByUserOrIP(action string) gin.HandlerFunc {
+	rl.register(action)
 	return func(c *gin.Context) {
-		var key string
-
 		if userID, exists := c.Get("user_id"); exists {
-			key = fmt.Sprintf("rate_limit:user:%d:%s", userID.(uint), action)
-		} else {
-			clientIP := c.ClientIP()
-			key = fmt.Sprintf("rate_limit:ip:%s:%s", clientIP, action)
-		}
-
-		if allowed, resetTime, err := rl.checkRateLimit(key); err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Rate limiting error")
-			c.Abort()
-			return
-		} else if !allowed {
-			c.Header("X-RateLimit-Limit", strconv.Itoa(rl.cfg.Requests))
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(resetTime, 10))
-			utils.ErrorResponse(c, http.StatusTooManyRequests,
-				fmt.Sprintf("Rate limit exceeded for %s. Try again later.", action))
-			c.Abort()
+			rl.enforce(c, action, userKey(userID.(uint), action))
 			return
 		}
-		c.Next()
+		rl.enforce(c, action, ipKey(c.ClientIP(), action))
 	}
 }
 
-// Check rate limit using sliding window rate limiting using Redis
-func (rl *RateLimiter) checkRateLimit(key string) (bool, int64, error) {
-	now := time.Now().Unix()
-	windowStart := now - int64(rl.cfg.Window.Seconds())
-
-	pipe := rl.client.Pipeline()
+func (rl *RateLimiter) register(action string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.actions[action] = struct{}{}
+}
 
-	// Remove expired entries
-	pipe.ZRemRangeByScore(rl.ctx, key, "0", fmt.Sprintf("%d", windowStart))
+func userKey(userID uint, action string) string {
+	return fmt.Sprintf("rate_limit:user:%d:%s", userID, action)
+}
 
-	// Count current requests in window
-	countCmd := pipe.ZCard(rl.ctx, key)
+func ipKey(clientIP, action string) string {
+	return fmt.Sprintf("rate_limit:ip:%s:%s", clientIP, action)
+}
 
-	// Add current request
-	pipe.ZAdd(rl.ctx, key, redis.Z{
-		Score:  float64(now),
-		Member: fmt.Sprintf("%d", now),
-	})
+// enforce counts the request against the policy for the caller's role and
+// sets the rate limit headers, aborting with a 429 once the limit is exceeded
+func (rl *RateLimiter) enforce(c *gin.Context, action, key string) {
+	var (
+		role   string
+		userID uint
+	)
+	if value, exists := c.Get("role"); exists {
+		role = string(value.(models.Role))
+	}
+	if value, exists := c.Get("user_id"); exists {
+		userID = value.(uint)
+	}
 
-	// Set expiry for the key
-	pipe.Expire(rl.ctx, key, rl.cfg.Window)
+	policy := rl.policies.Current().Resolve(action, role, userID)
+	if policy.Exempt {
+		c.Next()
+		return
+	}
 
-	_, err := pipe.Exec(rl.ctx)
+	result, err := rl.allow(c.Request.Context(), action, key, policy)
 	if err != nil {
-		return false, 0, err
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Rate limiting is temporarily unavailable")
+		c.Abort()
+		return
 	}
 
-	currentCount := countCmd.Val()
+	setRateLimitHeaders(c, policy.Limit, result)
 
-	// Check if limit exceeded (subtract 1 because we already added current request)
-	if currentCount > int64(rl.cfg.Requests) {
-		// Remove the request we just added since it exceeds limit
-		rl.client.ZRem(rl.ctx, key, fmt.Sprintf("%d", now))
-		return false, now + int64(rl.cfg.Window.Seconds()), nil
+	if !result.Allowed {
+		metrics.RateLimitRejections.WithLabelValues(action).Inc()
+		c.Header("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+		utils.ErrorResponse(c, http.StatusTooManyRequests,
+			fmt.Sprintf("Rate limit exceeded for %s. Try again later.", action))
+		c.Abort()
+		return
 	}
 
-	return true, 0, nil
+	c.Next()
 }
 
-// Custom rate limiter with different limits
-type CustomRateLimitConfig struct {
-	Requests int
-	Window   time.Duration
+// setRateLimitHeaders sends the X-RateLimit-* headers, with the reset as a
+// Unix time, and the IETF RateLimit-* headers, with the reset in seconds
+func setRateLimitHeaders(c *gin.Context, limit ratelimit.Limit, result ratelimit.Result) {
+	limitValue := strconv.Itoa(result.Limit)
+	remaining := strconv.Itoa(result.Remaining)
+	resetSeconds := ceilSeconds(result.ResetAfter)
+
+	c.Header("X-RateLimit-Limit", limitValue)
+	c.Header("X-RateLimit-Remaining", remaining)
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+resetSeconds, 10))
+
+	c.Header("RateLimit-Limit", limitValue)
+	c.Header("RateLimit-Remaining", remaining)
+	c.Header("RateLimit-Reset", strconv.FormatInt(resetSeconds, 10))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, int64(limit.Window.Seconds())))
 }
 
-func (rl *RateLimiter) CustomRateLimit(action string, config CustomRateLimitConfig) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var key string
-
-		if userID, exists := c.Get("user_id"); exists {
-			key = fmt.Sprintf("rate_limit:user:%d:%s", userID.(uint), action)
-		} else {
-			clientIP := c.ClientIP()
-			key = fmt.Sprintf("rate_limit:ip:%s:%s", clientIP, action)
-		}
-
-		if allowed, resetTime, err := rl.checkCustomRateLimit(key, config); err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "Rate limiting error")
-			c.Abort()
-			return
-		} else if !allowed {
-			c.Header("X-RateLimit-Limit", strconv.Itoa(config.Requests))
-			c.Header("X-RateLimit-Reset", strconv.FormatInt(resetTime, 10))
-			utils.ErrorResponse(c, http.StatusTooManyRequests,
-				fmt.Sprintf("Rate limit exceeded for %s. Try again later.", action))
-			c.Abort()
-			return
-		}
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
 
-		c.Next()
+// allow checks the limit in Redis. If Redis is unavailable the policy's
+// failure policy applies: fail open falls back to a per-instance limiter,
+// fail closed returns the error.
+func (rl *RateLimiter) allow(ctx context.Context, action, key string, policy ratelimit.Resolved) (ratelimit.Result, error) {
+	result, err := rl.store.Allow(ctx, key, policy.Limit)
+	if err == nil {
+		return result, nil
 	}
-}
 
-func (rl *RateLimiter) checkCustomRateLimit(key string, config CustomRateLimitConfig) (bool, int64, error) {
-	now := time.Now().Unix()
-	windowStart := now - int64(config.Window.Seconds())
+	failurePolicy := policy.FailurePolicy
+	if failurePolicy == "" {
+		failurePolicy = ratelimit.FailOpen
+	}
+	metrics.RateLimitStoreErrors.WithLabelValues(action, failurePolicy).Inc()
+	if failurePolicy == ratelimit.FailClosed {
+		logging.FromContext(ctx).Error("Rate limit store unavailable, rejecting request", "action", action, "error", err)
+		return ratelimit.Result{}, err
+	}
 
-	pipe := rl.client.Pipeline()
+	logging.FromContext(ctx).Warn("Rate limit store unavailable, using local limiter", "action", action, "error", err)
+	return rl.fallback.Allow(ctx, key, policy.Limit)
+}
 
-	// Remove expired entries
-	pipe.ZRemRangeByScore(rl.ctx, key, "0", fmt.Sprintf("%d", windowStart))
+// UserLimits reports the state of each rate limited action for a user
+// without counting a request
+func (rl *RateLimiter) UserLimits(ctx context.Context, userID uint, role models.Role) ([]models.RateLimitStatus, error) {
+	policies := rl.policies.Current()
 
-	// Count current requests in window
-	countCmd := pipe.ZCard(rl.ctx, key)
+	rl.mu.Lock()
+	seen := make(map[string]struct{}, len(rl.actions)+len(policies.Actions))
+	for action := range rl.actions {
+		seen[action] = struct{}{}
+	}
+	rl.mu.Unlock()
+	for action := range policies.Actions {
+		seen[action] = struct{}{}
+	}
 
-	// Add current request
-	pipe.ZAdd(rl.ctx, key, redis.Z{
-		Score:  float64(now),
-		Member: fmt.Sprintf("%d", now),
-	})
+	actions := make([]string, 0, len(seen))
+	for action := range seen {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	statuses := make([]models.RateLimitStatus, 0, len(actions))
+	for _, action := range actions {
+		policy := policies.Resolve(action, string(role), userID)
+		if policy.Exempt {
+			statuses = append(statuses, models.RateLimitStatus{Action: action, Exempt: true})
+			continue
+		}
 
-	// Set expiry for the key
-	pipe.Expire(rl.ctx, key, config.Window)
+		result, err := rl.store.Peek(ctx, userKey(userID, action), policy.Limit)
+		if err != nil {
+			return nil, err
+		}
 
-	_, err := pipe.Exec(rl.ctx)
-	if err != nil {
-		return false, 0, err
+		statuses = append(statuses, models.RateLimitStatus{
+			Action:            action,
+			Algorithm:         string(policy.Limit.Algorithm),
+			Limit:             policy.Limit.Requests,
+			WindowSeconds:     int64(policy.Limit.Window.Seconds()),
+			Remaining:         result.Remaining,
+			ResetAfterSeconds: ceilSeconds(result.ResetAfter),
+		})
 	}
 
-	currentCount := countCmd.Val()
+	return statuses, nil
+}
 
-	// Check if limit exceeded
-	if currentCount > int64(config.Requests) {
-		// Remove the request we just added since it exceeds limit
-		rl.client.ZRem(rl.ctx, key, fmt.Sprintf("%d", now))
-		return false, now + int64(config.Window.Seconds()), nil
+// ResetUser clears a user's counters for one action, or for every action if
+// action is empty
+func (rl *RateLimiter) ResetUser(ctx context.Context, userID uint, action string) error {
+	prefix := fmt.Sprintf("rate_limit:user:%d:", userID)
+	if action != "" {
+		prefix += action + ":"
 	}
 
-	return true, 0, nil
+	if err := rl.fallback.Reset(ctx, prefix); err != nil {
+		return err
+	}
+	return rl.store.Reset(ctx, prefix)
 }
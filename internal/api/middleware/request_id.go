@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"log/slog"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+
+	"social-media-app/internal/logging"
+	"social-media-app/internal/tracing"
+	"social-media-app/internal/utils"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Inbound IDs are echoed into logs and headers, so only accept simple tokens
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID tags each request with an ID, reusing the caller's X-Request-ID when
+// it is well formed. The ID is returned in the response and added to the
+// request's logger, along with the trace ID when the request is traced.
+func RequestID(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			generated, err := utils.GenerateRandomToken(16)
+			if err != nil {
+				logger.Error("Failed to generate request ID", "error", err)
+			}
+			requestID = generated
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(requestIDHeader, requestID)
+
+		requestLogger := logger.With("request_id", requestID)
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			requestLogger = requestLogger.With("trace_id", traceID)
+		}
+
+		ctx := logging.WithLogger(c.Request.Context(), requestLogger)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// withLogAttrs adds attributes to the logger carried by the request
+func withLogAttrs(c *gin.Context, args ...any) {
+	ctx := c.Request.Context()
+	c.Request = c.Request.WithContext(logging.WithLogger(ctx, logging.FromContext(ctx).With(args...)))
+}
@@ -1,18 +1,23 @@
 package api
 
 import (
+	"log/slog"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	"social-media-app/internal/api/handlers"
 	"social-media-app/internal/api/middleware"
 	"social-media-app/internal/config"
+	"social-media-app/internal/models"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/utils"
 )
 
-func SetupRoutes(cfg *config.Config) *gin.Engine {
+func SetupRoutes(cfg *config.Config, logger *slog.Logger, keys *utils.KeySet, users middleware.UserLookup, idempotencyRepo repository.IdempotencyRepository, rateLimiter *middleware.RateLimiter) *gin.Engine {
 	// Set Gin mode
 	if cfg.Server.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -20,11 +25,10 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 
 	router := gin.New()
 
-	// Initialize rate limiter
-	rateLimiter := middleware.NewRateLimiter(cfg)
-
 	// Global middleware
-	router.Use(gin.Logger())
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(tracedRequest)))
+	router.Use(middleware.RequestID(logger))
+	router.Use(middleware.RequestLogger())
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
 	router.Use(middleware.PrometheusMiddleware())
@@ -37,8 +41,21 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		})
 	})
 
+	// Kubernetes style probes
+	router.GET("/livez", handlers.Livez)
+	router.GET("/readyz", handlers.Readyz)
+
+	// Public keys for verifying issued tokens
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keys.JWKS())
+	})
+
 	// Metrics endpoint
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	// OpenMetrics is needed to expose trace exemplars
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})))
 
 	// API routes
 	api := router.Group("/api")
@@ -46,20 +63,27 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		// Authentication routes (rate limited by IP)
 		auth := api.Group("/auth")
 		{
-			// More restrictive rate limiting for auth endpoints
-			authRateLimit := middleware.CustomRateLimitConfig{
-				Requests: 10, // 10 requests per hour for auth
-				Window:   time.Hour,
-			}
-
 			auth.POST("/register",
-				rateLimiter.CustomRateLimit("register", authRateLimit),
+				rateLimiter.RateLimitByUserOrIP("register"),
 				handlers.Register,
 			)
 			auth.POST("/login",
-				rateLimiter.CustomRateLimit("login", authRateLimit),
+				rateLimiter.RateLimitByUserOrIP("login"),
 				handlers.Login,
 			)
+			auth.POST("/2fa/verify",
+				rateLimiter.RateLimitByUserOrIP("verify_2fa"),
+				handlers.VerifyTwoFactor,
+			)
+			auth.GET("/oauth/:provider",
+				rateLimiter.RateLimitByUserOrIP("oauth_login"),
+				handlers.OAuthLogin,
+			)
+			// Each callback exchanges a code with the provider
+			auth.GET("/oauth/:provider/callback",
+				rateLimiter.RateLimitByIP("oauth_callback"),
+				handlers.OAuthCallback,
+			)
 			auth.GET("/login", func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{
 					"message": "Please use POST method for login",
@@ -70,7 +94,8 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 
 		// Protected routes (require authentication)
 		protected := api.Group("/")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(keys, users))
+		protected.Use(middleware.Idempotency(idempotencyRepo, cfg.Server.IdempotencyTTL))
 		{
 			// User routes (light rate limiting)
 			users := protected.Group("/users")
@@ -87,18 +112,31 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 				)
 			}
 
+			// Two-factor authentication management
+			twoFactor := protected.Group("/2fa")
+			{
+				twoFactor.POST("/enroll",
+					rateLimiter.RateLimitByUser("manage_2fa"),
+					handlers.EnrollTwoFactor,
+				)
+				twoFactor.POST("/activate",
+					rateLimiter.RateLimitByUser("manage_2fa"),
+					handlers.ActivateTwoFactor,
+				)
+				twoFactor.POST("/disable",
+					rateLimiter.RateLimitByUser("manage_2fa"),
+					handlers.DisableTwoFactor,
+				)
+			}
+
 			// Post routes (moderate rate limiting)
 			posts := protected.Group("/posts")
 			{
 				posts.GET("/", handlers.GetPosts)
 
 				// Stricter rate limiting for post creation
-				postCreateRateLimit := middleware.CustomRateLimitConfig{
-					Requests: 20, // 20 posts per hour
-					Window:   time.Hour,
-				}
 				posts.POST("/",
-					rateLimiter.CustomRateLimit("create_post", postCreateRateLimit),
+					rateLimiter.RateLimitByUserOrIP("create_post"),
 					handlers.CreatePost,
 				)
 
@@ -117,17 +155,12 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			// Like routes (stricter rate limiting to prevent spam)
 			likes := protected.Group("/likes")
 			{
-				likeRateLimit := middleware.CustomRateLimitConfig{
-					Requests: 60, // 60 likes per hour
-					Window:   time.Hour,
-				}
-
 				likes.POST("/",
-					rateLimiter.CustomRateLimit("like_post", likeRateLimit),
+					rateLimiter.RateLimitByUserOrIP("like_post"),
 					handlers.LikePost,
 				)
 				likes.DELETE("/:post_id",
-					rateLimiter.CustomRateLimit("unlike_post", likeRateLimit),
+					rateLimiter.RateLimitByUserOrIP("unlike_post"),
 					handlers.UnlikePost,
 				)
 			}
@@ -135,23 +168,75 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			// Follow routes (moderate rate limiting)
 			follows := protected.Group("/follows")
 			{
-				followRateLimit := middleware.CustomRateLimitConfig{
-					Requests: 50, // 50 follows per hour
-					Window:   time.Hour,
-				}
-
 				follows.POST("/",
-					rateLimiter.CustomRateLimit("follow_user", followRateLimit),
+					rateLimiter.RateLimitByUserOrIP("follow_user"),
 					handlers.FollowUser,
 				)
 				follows.DELETE("/:user_id",
-					rateLimiter.CustomRateLimit("unfollow_user", followRateLimit),
+					rateLimiter.RateLimitByUserOrIP("unfollow_user"),
 					handlers.UnfollowUser,
 				)
 				follows.GET("/followers/:user_id", handlers.GetFollowers)
 				follows.GET("/following/:user_id", handlers.GetFollowing)
 			}
 
+			// Content reporting (stricter rate limiting to prevent abuse)
+			reports := protected.Group("/reports")
+			{
+				reports.POST("/",
+					rateLimiter.RateLimitByUserOrIP("create_report"),
+					handlers.CreateReport,
+				)
+			}
+
+			// Admin and moderation routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireRole(models.RoleAdmin, models.RoleModerator))
+			{
+				admin.GET("/users",
+					middleware.RequirePermission(models.PermissionManageUsers),
+					handlers.AdminListUsers,
+				)
+				admin.PUT("/users/:id/suspend",
+					middleware.RequirePermission(models.PermissionManageUsers),
+					handlers.AdminSuspendUser,
+				)
+				admin.PUT("/users/:id/unsuspend",
+					middleware.RequirePermission(models.PermissionManageUsers),
+					handlers.AdminUnsuspendUser,
+				)
+				admin.GET("/users/:id/rate-limits",
+					middleware.RequirePermission(models.PermissionManageUsers),
+					handlers.AdminGetUserRateLimits,
+				)
+				admin.DELETE("/users/:id/rate-limits",
+					middleware.RequirePermission(models.PermissionManageUsers),
+					handlers.AdminResetUserRateLimits,
+				)
+				admin.PUT("/users/:id/role",
+					middleware.RequirePermission(models.PermissionManageRoles),
+					handlers.AdminUpdateUserRole,
+				)
+				admin.DELETE("/posts/:id",
+					middleware.RequirePermission(models.PermissionDeleteAnyPost),
+					handlers.AdminDeletePost,
+				)
+				admin.GET("/audit",
+					middleware.RequirePermission(models.PermissionViewAuditLog),
+					handlers.AdminListAuditLogs,
+				)
+
+				// Moderation queue
+				reportQueue := admin.Group("/reports")
+				reportQueue.Use(middleware.RequirePermission(models.PermissionModerate))
+				{
+					reportQueue.GET("/", handlers.ListReports)
+					reportQueue.POST("/:id/assign", handlers.AssignReport)
+					reportQueue.POST("/:id/resolve", handlers.ResolveReport)
+					reportQueue.POST("/:id/dismiss", handlers.DismissReport)
+				}
+			}
+
 			// Timeline route (light rate limiting)
 			timeline := protected.Group("/timeline")
 			{
@@ -187,3 +272,12 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 
 	return router
 }
+
+// tracedRequest leaves probes and metric scrapes out of traces
+func tracedRequest(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/health", "/livez", "/readyz", "/metrics":
+		return false
+	}
+	return true
+}
@@ -1,132 +1,440 @@
 package config
 
 import (
-	"log"
+	"bytes"
+	"flag"
+	"fmt"
 	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+
+	"social-media-app/internal/ratelimit"
 )
 
 type Config struct {
-	Database  DatabaseConfig
-	Redis     RedisConfig
-	JWT       JWTConfig
-	Server    ServerConfig
-	RateLimit RateLimitConfig
+	Database      DatabaseConfig      `yaml:"database"`
+	Redis         RedisConfig         `yaml:"redis"`
+	Cache         CacheConfig         `yaml:"cache"`
+	JWT           JWTConfig           `yaml:"jwt"`
+	Server        ServerConfig        `yaml:"server"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	Auth          AuthConfig          `yaml:"auth"`
+	OAuth         OAuthConfig         `yaml:"oauth"`
+	Moderation    ModerationConfig    `yaml:"moderation"`
+	ContentFilter ContentFilterConfig `yaml:"content_filter"`
+	Log           LogConfig           `yaml:"log"`
+	Tracing       TracingConfig       `yaml:"tracing"`
+
+	// DotEnvPath is the .env file variables were loaded from, if any
+	DotEnvPath string `yaml:"-"`
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"name"`
+	SSLMode  string `yaml:"ssl_mode"`
+	// Queries slower than this are logged, 0 disables slow query logging
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
+	// Connection pool limits, 0 leaves the database/sql default
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+	// How long to keep retrying while Postgres starts up, 0 fails on the first attempt
+	ConnectRetryTimeout time.Duration `yaml:"connect_retry_timeout"`
+	// Statements running longer than this are cancelled by Postgres, 0 disables the limit
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+	// host:port of read replicas, sharing the primary's credentials. Reads
+	// that can tolerate replication lag are spread across them.
+	Replicas []string `yaml:"replicas"`
 }
 
 type RedisConfig struct {
-	Host     string
-	Port     string
-	Password string
-	DB       int
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+	// Connections per client, 0 leaves the go-redis default of 10 per CPU
+	PoolSize     int           `yaml:"pool_size"`
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// Consecutive failures before commands are short-circuited, and how long
+	// to wait before trying Redis again
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
 }
 
+// CacheConfig sets how long posts and timelines stay in the Redis cache
+type CacheConfig struct {
+	PostTTL     time.Duration `yaml:"post_ttl"`
+	TimelineTTL time.Duration `yaml:"timeline_ttl"`
+	// How eagerly entries are refreshed before they expire, scaled by how
+	// long they took to load. 0 disables early refresh.
+	EarlyRefreshBeta float64 `yaml:"early_refresh_beta"`
+}
+
+// JWTConfig points at the PEM keys used to sign tokens. Every <kid>.pem file in
+// KeysDir is accepted for verification; ActiveKeyID selects the signing key.
 type JWTConfig struct {
-	Secret string
-	Expiry time.Duration
+	KeysDir     string        `yaml:"keys_dir"`
+	ActiveKeyID string        `yaml:"active_key_id"`
+	Expiry      time.Duration `yaml:"expiry"`
 }
 
 type ServerConfig struct {
-	Host string
-	Port string
-	Env  string
+	Host           string        `yaml:"host"`
+	Port           string        `yaml:"port"`
+	Env            string        `yaml:"environment"`
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"` // how long responses are kept for Idempotency-Key retries
+	// How long /readyz reports draining before the server stops accepting connections
+	DrainDelay      time.Duration `yaml:"drain_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // how long in-flight requests get to finish
 }
 
 type RateLimitConfig struct {
-	Requests int
-	Window   time.Duration
+	Requests      int                 `yaml:"requests"`
+	Window        time.Duration       `yaml:"window"`
+	Algorithm     ratelimit.Algorithm `yaml:"algorithm"`
+	FailurePolicy string              `yaml:"failure_policy"`
+	// Per action overrides of FailurePolicy
+	ActionFailurePolicies map[string]string `yaml:"action_failure_policies"`
+	// YAML file of per action limits layered over the defaults, checked for
+	// changes every PolicyReloadInterval
+	PolicyFile           string        `yaml:"policy_file"`
+	PolicyReloadInterval time.Duration `yaml:"policy_reload_interval"`
 }
 
-func Load() *Config {
-	envPaths := []string{
-		".env",
-		"../.env",
-	}
+type AuthConfig struct {
+	MaxLoginAttempts int           `yaml:"max_login_attempts"`
+	AttemptWindow    time.Duration `yaml:"attempt_window"`
+	LockoutBase      time.Duration `yaml:"lockout_base"`
+	LockoutMax       time.Duration `yaml:"lockout_max"`
+	// What happens when login attempts can't be counted: "open" allows the
+	// login without counting it, "closed" rejects it
+	LockoutFailurePolicy string        `yaml:"lockout_failure_policy"`
+	TOTPIssuer           string        `yaml:"totp_issuer"`
+	ChallengeExpiry      time.Duration `yaml:"challenge_expiry"`
+}
+
+// OAuthConfig holds the OpenID Connect providers users can sign in with
+type OAuthConfig struct {
+	Providers map[string]OAuthProviderConfig `yaml:"providers"`
+	StateTTL  time.Duration                  `yaml:"state_ttl"`
+}
+
+type OAuthProviderConfig struct {
+	Name         string   `yaml:"-"` // the key in Providers
+	IssuerURL    string   `yaml:"issuer_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// Scopes requested from providers that don't configure their own
+var defaultOAuthScopes = []string{"openid", "email", "profile"}
+
+type ModerationConfig struct {
+	// Number of distinct users reporting a post before it is hidden pending review
+	ReportHideThreshold int `yaml:"report_hide_threshold"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"` // debug, info, warn or error
+}
+
+// TracingConfig configures the OTLP exporter spans are sent to
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	ServiceName string  `yaml:"service_name"`
+	Endpoint    string  `yaml:"otlp_endpoint"` // host:port of the collector
+	Protocol    string  `yaml:"otlp_protocol"` // grpc or http
+	Insecure    bool    `yaml:"otlp_insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"` // fraction of new traces recorded, between 0 and 1
+}
 
-	envLoaded := false
-	for _, path := range envPaths {
-		err := godotenv.Load(path)
-		if err == nil {
-			log.Printf("Loaded .env file from %s", path)
-			envLoaded = true
-			break
-		} else {
-			log.Printf("No .env file found at %s, error: %v", path, err)
+// ContentFilterConfig configures the filters run when posts are created or edited
+type ContentFilterConfig struct {
+	BlockedWords   []string `yaml:"blocked_words"`
+	BlockedDomains []string `yaml:"blocked_domains"`
+	// Files with one entry per line, added to the lists above
+	BlockedWordsFile   string        `yaml:"blocked_words_file"`
+	BlockedDomainsFile string        `yaml:"blocked_domains_file"`
+	DuplicateWindow    time.Duration `yaml:"duplicate_window"` // 0 disables the duplicate post check
+	MaxLinks           int           `yaml:"max_links"`
+	MaxLinkDensity     float64       `yaml:"max_link_density"` // links per word
+}
+
+// Flags are the command line options that override the configuration
+type Flags struct {
+	fs *flag.FlagSet
+
+	File     string
+	Host     string
+	Port     string
+	Env      string
+	LogLevel string
+}
+
+// RegisterFlags adds the configuration flags to fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	fs.StringVar(&f.File, "config", "", "YAML config file, overrides CONFIG_FILE")
+	fs.StringVar(&f.Host, "host", "", "address to listen on, overrides SERVER_HOST")
+	fs.StringVar(&f.Port, "port", "", "port to listen on, overrides SERVER_PORT")
+	fs.StringVar(&f.Env, "env", "", "environment name, overrides ENVIRONMENT")
+	fs.StringVar(&f.LogLevel, "log-level", "", "debug, info, warn or error, overrides LOG_LEVEL")
+	return f
+}
+
+// apply sets the fields for every flag given on the command line
+func (f *Flags) apply(cfg *Config) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "host":
+			cfg.Server.Host = f.Host
+		case "port":
+			cfg.Server.Port = f.Port
+		case "env":
+			cfg.Server.Env = f.Env
+		case "log-level":
+			cfg.Log.Level = f.LogLevel
 		}
-	}
+	})
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the YAML file named by --config or CONFIG_FILE, environment
+// variables and command line flags. Values that fail to parse or validate
+// are all reported in the returned error.
+func Load(flags *Flags) (*Config, error) {
+	dotEnvPath := loadDotEnv()
+
+	cfg := defaults()
+	cfg.DotEnvPath = dotEnvPath
 
-	if !envLoaded {
-		log.Println("No .env file found at any location, using environment variables")
+	path := os.Getenv("CONFIG_FILE")
+	if flags != nil && flags.File != "" {
+		path = flags.File
+	}
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
 	}
 
-	// Parse JWT expiry
-	jwtExpiry, err := time.ParseDuration(getEnv("JWT_EXPIRY", "24h"))
-	if err != nil {
-		jwtExpiry = 24 * time.Hour
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	if flags != nil {
+		flags.apply(cfg)
 	}
 
-	// Parse rate limit window
-	rateLimitWindow, err := time.ParseDuration(getEnv("RATE_LIMIT_WINDOW", "1h"))
-	if err != nil {
-		rateLimitWindow = time.Hour
+	// Debug logging by default everywhere except production
+	if cfg.Log.Level == "" {
+		cfg.Log.Level = "debug"
+		if cfg.IsProduction() {
+			cfg.Log.Level = "info"
+		}
 	}
 
-	// Parse rate limit requests
-	rateLimitRequests, err := strconv.Atoi(getEnv("RATE_LIMIT_REQUESTS", "100"))
-	if err != nil {
-		rateLimitRequests = 100
+	if err := cfg.loadLists(); err != nil {
+		return nil, err
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
 
+// defaults returns the configuration used when nothing else is set. Secrets
+// have no defaults.
+func defaults() *Config {
 	return &Config{
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "admin"),
-			Password: getEnv("DB_PASSWORD", "password123"),
-			DBName:   getEnv("DB_NAME", "social_media"),
-			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+			Host:                "localhost",
+			Port:                "5432",
+			User:                "admin",
+			DBName:              "social_media",
+			SSLMode:             "disable",
+			SlowQueryThreshold:  200 * time.Millisecond,
+			MaxOpenConns:        25,
+			MaxIdleConns:        10,
+			ConnMaxLifetime:     30 * time.Minute,
+			ConnMaxIdleTime:     5 * time.Minute,
+			ConnectTimeout:      5 * time.Second,
+			ConnectRetryTimeout: 30 * time.Second,
 		},
 		Redis: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnv("REDIS_PORT", "6379"),
-			Password: getEnv("REDIS_PASSWORD", "password123"),
-			DB:       0,
+			Host:             "localhost",
+			Port:             "6379",
+			DialTimeout:      5 * time.Second,
+			ReadTimeout:      3 * time.Second,
+			WriteTimeout:     3 * time.Second,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+		},
+		Cache: CacheConfig{
+			PostTTL:          10 * time.Minute,
+			TimelineTTL:      5 * time.Minute,
+			EarlyRefreshBeta: 1,
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", generateSecureJWTSecret()),
-			Expiry: jwtExpiry,
+			Expiry: 24 * time.Hour,
 		},
 		Server: ServerConfig{
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
-			Port: getEnv("SERVER_PORT", "8080"),
-			Env:  getEnv("ENVIRONMENT", "development"),
+			Host:            "0.0.0.0",
+			Port:            "8080",
+			Env:             "development",
+			IdempotencyTTL:  24 * time.Hour,
+			DrainDelay:      5 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
 		},
 		RateLimit: RateLimitConfig{
-			Requests: rateLimitRequests,
-			Window:   rateLimitWindow,
+			Requests:              100,
+			Window:                time.Hour,
+			Algorithm:             ratelimit.SlidingWindow,
+			FailurePolicy:         ratelimit.FailOpen,
+			ActionFailurePolicies: map[string]string{},
+			PolicyReloadInterval:  30 * time.Second,
+		},
+		Auth: AuthConfig{
+			MaxLoginAttempts:     5,
+			AttemptWindow:        15 * time.Minute,
+			LockoutBase:          time.Minute,
+			LockoutMax:           time.Hour,
+			LockoutFailurePolicy: ratelimit.FailOpen,
+			TOTPIssuer:           "Postly",
+			ChallengeExpiry:      5 * time.Minute,
+		},
+		OAuth: OAuthConfig{
+			Providers: map[string]OAuthProviderConfig{},
+			StateTTL:  10 * time.Minute,
 		},
+		Moderation: ModerationConfig{
+			ReportHideThreshold: 5,
+		},
+		ContentFilter: ContentFilterConfig{
+			DuplicateWindow: time.Hour,
+			MaxLinks:        3,
+			MaxLinkDensity:  0.5,
+		},
+		Tracing: TracingConfig{
+			ServiceName: "social-media-api",
+			Endpoint:    "localhost:4317",
+			Protocol:    "grpc",
+			Insecure:    true,
+			SampleRatio: 1,
+		},
+	}
+}
+
+// IsProduction reports whether the server is running in production
+func (c *Config) IsProduction() bool {
+	return c.Server.Env == "production"
+}
+
+// loadDotEnv adds variables from a .env file to the environment without
+// overriding those already set, and returns its path. It runs before logging
+// is set up, so the caller logs it.
+func loadDotEnv() string {
+	for _, path := range []string{".env", "../.env"} {
+		if err := godotenv.Load(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadFile reads a YAML config file over cfg. Unknown keys are rejected so
+// typos don't go unnoticed.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for name, provider := range cfg.OAuth.Providers {
+		provider.Name = name
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = defaultOAuthScopes
+		}
+		cfg.OAuth.Providers[name] = provider
+	}
+	return nil
+}
+
+// loadLists adds the entries in the blocked word and domain files to the lists
+func (c *Config) loadLists() error {
+	var err error
+	if c.ContentFilter.BlockedWords, err = appendFileLines(c.ContentFilter.BlockedWords, c.ContentFilter.BlockedWordsFile); err != nil {
+		return err
+	}
+	if c.ContentFilter.BlockedDomains, err = appendFileLines(c.ContentFilter.BlockedDomains, c.ContentFilter.BlockedDomainsFile); err != nil {
+		return err
+	}
+	return nil
+}
+
+// appendFileLines appends each line of the file at path, skipping blank
+// lines and # comments
+func appendFileLines(items []string, path string) ([]string, error) {
+	if path == "" {
+		return items, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read list: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			items = append(items, line)
+		}
+	}
+	return items, nil
+}
+
+// redactedValue replaces secrets in printed configuration
+const redactedValue = "[redacted]"
+
+// Redacted returns a copy of the configuration with passwords and client
+// secrets replaced, safe to print or log
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Database.Password = redact(c.Database.Password)
+	redacted.Redis.Password = redact(c.Redis.Password)
+
+	redacted.OAuth.Providers = make(map[string]OAuthProviderConfig, len(c.OAuth.Providers))
+	for name, provider := range c.OAuth.Providers {
+		provider.ClientSecret = redact(provider.ClientSecret)
+		redacted.OAuth.Providers[name] = provider
 	}
+	return &redacted
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func redact(secret string) string {
+	if secret == "" {
+		return ""
 	}
-	return defaultValue
+	return redactedValue
 }
 
-func generateSecureJWTSecret() string {
-	return os.Getenv("JWT_SECRET")
+// YAML encodes the configuration in the format read from config files
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
 }
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides cfg with the environment variables that are set.
+// Every value that fails to parse is reported, not just the first.
+func applyEnv(cfg *Config) error {
+	env := &envReader{}
+
+	env.string("DB_HOST", &cfg.Database.Host)
+	env.string("DB_PORT", &cfg.Database.Port)
+	env.string("DB_USER", &cfg.Database.User)
+	env.string("DB_PASSWORD", &cfg.Database.Password)
+	env.string("DB_NAME", &cfg.Database.DBName)
+	env.string("DB_SSL_MODE", &cfg.Database.SSLMode)
+	env.duration("DB_SLOW_QUERY_THRESHOLD", &cfg.Database.SlowQueryThreshold)
+	env.int("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	env.int("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns)
+	env.duration("DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime)
+	env.duration("DB_CONN_MAX_IDLE_TIME", &cfg.Database.ConnMaxIdleTime)
+	env.duration("DB_CONNECT_TIMEOUT", &cfg.Database.ConnectTimeout)
+	env.duration("DB_CONNECT_RETRY_TIMEOUT", &cfg.Database.ConnectRetryTimeout)
+	env.duration("DB_STATEMENT_TIMEOUT", &cfg.Database.StatementTimeout)
+	env.list("DB_REPLICAS", &cfg.Database.Replicas)
+
+	env.string("REDIS_HOST", &cfg.Redis.Host)
+	env.string("REDIS_PORT", &cfg.Redis.Port)
+	env.string("REDIS_PASSWORD", &cfg.Redis.Password)
+	env.int("REDIS_DB", &cfg.Redis.DB)
+	env.int("REDIS_POOL_SIZE", &cfg.Redis.PoolSize)
+	env.duration("REDIS_DIAL_TIMEOUT", &cfg.Redis.DialTimeout)
+	env.duration("REDIS_READ_TIMEOUT", &cfg.Redis.ReadTimeout)
+	env.duration("REDIS_WRITE_TIMEOUT", &cfg.Redis.WriteTimeout)
+	env.int("REDIS_BREAKER_THRESHOLD", &cfg.Redis.BreakerThreshold)
+	env.duration("REDIS_BREAKER_COOLDOWN", &cfg.Redis.BreakerCooldown)
+
+	env.duration("CACHE_POST_TTL", &cfg.Cache.PostTTL)
+	env.duration("CACHE_TIMELINE_TTL", &cfg.Cache.TimelineTTL)
+	env.float("CACHE_EARLY_REFRESH_BETA", &cfg.Cache.EarlyRefreshBeta)
+
+	env.string("JWT_KEYS_DIR", &cfg.JWT.KeysDir)
+	env.string("JWT_ACTIVE_KEY_ID", &cfg.JWT.ActiveKeyID)
+	env.duration("JWT_EXPIRY", &cfg.JWT.Expiry)
+
+	env.string("SERVER_HOST", &cfg.Server.Host)
+	env.string("SERVER_PORT", &cfg.Server.Port)
+	env.string("ENVIRONMENT", &cfg.Server.Env)
+	env.duration("IDEMPOTENCY_KEY_TTL", &cfg.Server.IdempotencyTTL)
+	env.duration("SHUTDOWN_DRAIN_DELAY", &cfg.Server.DrainDelay)
+	env.duration("SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	env.int("RATE_LIMIT_REQUESTS", &cfg.RateLimit.Requests)
+	env.duration("RATE_LIMIT_WINDOW", &cfg.RateLimit.Window)
+	env.string("RATE_LIMIT_ALGORITHM", (*string)(&cfg.RateLimit.Algorithm))
+	env.string("RATE_LIMIT_FAILURE_POLICY", &cfg.RateLimit.FailurePolicy)
+	env.pairs("RATE_LIMIT_ACTION_FAILURE_POLICIES", &cfg.RateLimit.ActionFailurePolicies)
+	env.string("RATE_LIMIT_POLICY_FILE", &cfg.RateLimit.PolicyFile)
+	env.duration("RATE_LIMIT_POLICY_RELOAD_INTERVAL", &cfg.RateLimit.PolicyReloadInterval)
+
+	env.int("LOGIN_MAX_ATTEMPTS", &cfg.Auth.MaxLoginAttempts)
+	env.duration("LOGIN_ATTEMPT_WINDOW", &cfg.Auth.AttemptWindow)
+	env.duration("LOGIN_LOCKOUT_BASE", &cfg.Auth.LockoutBase)
+	env.duration("LOGIN_LOCKOUT_MAX", &cfg.Auth.LockoutMax)
+	env.string("LOGIN_LOCKOUT_FAILURE_POLICY", &cfg.Auth.LockoutFailurePolicy)
+	env.string("TOTP_ISSUER", &cfg.Auth.TOTPIssuer)
+	env.duration("TWO_FACTOR_CHALLENGE_EXPIRY", &cfg.Auth.ChallengeExpiry)
+
+	env.oauthProviders(cfg.OAuth.Providers)
+	env.duration("OAUTH_STATE_TTL", &cfg.OAuth.StateTTL)
+
+	env.int("REPORT_HIDE_THRESHOLD", &cfg.Moderation.ReportHideThreshold)
+
+	env.list("CONTENT_BLOCKED_WORDS", &cfg.ContentFilter.BlockedWords)
+	env.list("CONTENT_BLOCKED_DOMAINS", &cfg.ContentFilter.BlockedDomains)
+	env.string("CONTENT_BLOCKED_WORDS_FILE", &cfg.ContentFilter.BlockedWordsFile)
+	env.string("CONTENT_BLOCKED_DOMAINS_FILE", &cfg.ContentFilter.BlockedDomainsFile)
+	env.duration("CONTENT_DUPLICATE_WINDOW", &cfg.ContentFilter.DuplicateWindow)
+	env.int("CONTENT_MAX_LINKS", &cfg.ContentFilter.MaxLinks)
+	env.float("CONTENT_MAX_LINK_DENSITY", &cfg.ContentFilter.MaxLinkDensity)
+
+	env.string("LOG_LEVEL", &cfg.Log.Level)
+
+	env.bool("TRACING_ENABLED", &cfg.Tracing.Enabled)
+	env.string("TRACING_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	env.string("TRACING_OTLP_ENDPOINT", &cfg.Tracing.Endpoint)
+	env.string("TRACING_OTLP_PROTOCOL", &cfg.Tracing.Protocol)
+	env.bool("TRACING_OTLP_INSECURE", &cfg.Tracing.Insecure)
+	env.float("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+
+	return errors.Join(env.errs...)
+}
+
+// envReader sets config fields from environment variables, collecting the
+// values that fail to parse. Unset and empty variables leave the field as is.
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) lookup(key string) (string, bool) {
+	value := strings.TrimSpace(os.Getenv(key))
+	return value, value != ""
+}
+
+func (r *envReader) invalid(key, value, kind string) {
+	r.errs = append(r.errs, fmt.Errorf("%s: %q is not a valid %s", key, value, kind))
+}
+
+func (r *envReader) string(key string, dst *string) {
+	if value, ok := r.lookup(key); ok {
+		*dst = value
+	}
+}
+
+func (r *envReader) int(key string, dst *int) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		r.invalid(key, value, "integer")
+		return
+	}
+	*dst = n
+}
+
+func (r *envReader) float(key string, dst *float64) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		r.invalid(key, value, "number")
+		return
+	}
+	*dst = f
+}
+
+func (r *envReader) bool(key string, dst *bool) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		r.invalid(key, value, "boolean")
+		return
+	}
+	*dst = b
+}
+
+func (r *envReader) duration(key string, dst *time.Duration) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		r.invalid(key, value, "duration")
+		return
+	}
+	*dst = d
+}
+
+// list reads a comma separated list
+func (r *envReader) list(key string, dst *[]string) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+// pairs reads comma separated key=value pairs, e.g. "login=closed,search=open",
+// into dst
+func (r *envReader) pairs(key string, dst *map[string]string) {
+	value, ok := r.lookup(key)
+	if !ok {
+		return
+	}
+	if *dst == nil {
+		*dst = make(map[string]string)
+	}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(entry, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			r.invalid(key, entry, "key=value pair")
+			continue
+		}
+		(*dst)[k] = strings.ToLower(strings.TrimSpace(v))
+	}
+}
+
+// oauthProviders reads providers listed in OAUTH_PROVIDERS (e.g. "google,gitlab").
+// Each provider is configured with OAUTH_<NAME>_ISSUER_URL, _CLIENT_ID,
+// _CLIENT_SECRET, _REDIRECT_URL and optionally _SCOPES (comma separated), over
+// any settings for the provider from the config file.
+func (r *envReader) oauthProviders(providers map[string]OAuthProviderConfig) {
+	value, ok := r.lookup("OAUTH_PROVIDERS")
+	if !ok {
+		return
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+		provider := providers[name]
+		provider.Name = name
+		r.string(prefix+"ISSUER_URL", &provider.IssuerURL)
+		r.string(prefix+"CLIENT_ID", &provider.ClientID)
+		r.string(prefix+"CLIENT_SECRET", &provider.ClientSecret)
+		r.string(prefix+"REDIRECT_URL", &provider.RedirectURL)
+		r.list(prefix+"SCOPES", &provider.Scopes)
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = defaultOAuthScopes
+		}
+
+		providers[name] = provider
+	}
+}
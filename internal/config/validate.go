@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"social-media-app/internal/ratelimit"
+)
+
+// Validate checks the configuration and reports every problem found, naming
+// each setting by its environment variable
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.require("DB_HOST", c.Database.Host)
+	v.port("DB_PORT", c.Database.Port)
+	v.require("DB_USER", c.Database.User)
+	v.require("DB_NAME", c.Database.DBName)
+	v.oneOf("DB_SSL_MODE", c.Database.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	nonNegative(v, "DB_MAX_OPEN_CONNS", c.Database.MaxOpenConns)
+	nonNegative(v, "DB_MAX_IDLE_CONNS", c.Database.MaxIdleConns)
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		v.fail("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+	nonNegative(v, "DB_CONN_MAX_LIFETIME", c.Database.ConnMaxLifetime)
+	nonNegative(v, "DB_CONN_MAX_IDLE_TIME", c.Database.ConnMaxIdleTime)
+	nonNegative(v, "DB_CONNECT_TIMEOUT", c.Database.ConnectTimeout)
+	nonNegative(v, "DB_CONNECT_RETRY_TIMEOUT", c.Database.ConnectRetryTimeout)
+	nonNegative(v, "DB_STATEMENT_TIMEOUT", c.Database.StatementTimeout)
+	nonNegative(v, "DB_SLOW_QUERY_THRESHOLD", c.Database.SlowQueryThreshold)
+	for _, replica := range c.Database.Replicas {
+		if _, port, err := net.SplitHostPort(replica); err != nil {
+			v.fail("DB_REPLICAS: %q must be host:port", replica)
+		} else {
+			v.port("DB_REPLICAS", port)
+		}
+	}
+
+	v.require("REDIS_HOST", c.Redis.Host)
+	v.port("REDIS_PORT", c.Redis.Port)
+	if c.Redis.DB < 0 || c.Redis.DB > 15 {
+		v.fail("REDIS_DB must be between 0 and 15, got %d", c.Redis.DB)
+	}
+	nonNegative(v, "REDIS_POOL_SIZE", c.Redis.PoolSize)
+	nonNegative(v, "REDIS_DIAL_TIMEOUT", c.Redis.DialTimeout)
+	nonNegative(v, "REDIS_READ_TIMEOUT", c.Redis.ReadTimeout)
+	nonNegative(v, "REDIS_WRITE_TIMEOUT", c.Redis.WriteTimeout)
+	positive(v, "REDIS_BREAKER_THRESHOLD", c.Redis.BreakerThreshold)
+	positive(v, "REDIS_BREAKER_COOLDOWN", c.Redis.BreakerCooldown)
+
+	positive(v, "CACHE_POST_TTL", c.Cache.PostTTL)
+	positive(v, "CACHE_TIMELINE_TTL", c.Cache.TimelineTTL)
+	nonNegative(v, "CACHE_EARLY_REFRESH_BETA", c.Cache.EarlyRefreshBeta)
+
+	positive(v, "JWT_EXPIRY", c.JWT.Expiry)
+
+	v.port("SERVER_PORT", c.Server.Port)
+	positive(v, "IDEMPOTENCY_KEY_TTL", c.Server.IdempotencyTTL)
+	nonNegative(v, "SHUTDOWN_DRAIN_DELAY", c.Server.DrainDelay)
+	positive(v, "SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+
+	positive(v, "RATE_LIMIT_REQUESTS", c.RateLimit.Requests)
+	positive(v, "RATE_LIMIT_WINDOW", c.RateLimit.Window)
+	if _, err := ratelimit.ParseAlgorithm(string(c.RateLimit.Algorithm)); err != nil {
+		v.fail("RATE_LIMIT_ALGORITHM: %v", err)
+	}
+	v.oneOf("RATE_LIMIT_FAILURE_POLICY", c.RateLimit.FailurePolicy, ratelimit.FailOpen, ratelimit.FailClosed)
+	for action, policy := range c.RateLimit.ActionFailurePolicies {
+		v.oneOf("RATE_LIMIT_ACTION_FAILURE_POLICIES "+action, policy, ratelimit.FailOpen, ratelimit.FailClosed)
+	}
+	nonNegative(v, "RATE_LIMIT_POLICY_RELOAD_INTERVAL", c.RateLimit.PolicyReloadInterval)
+
+	positive(v, "LOGIN_MAX_ATTEMPTS", c.Auth.MaxLoginAttempts)
+	positive(v, "LOGIN_ATTEMPT_WINDOW", c.Auth.AttemptWindow)
+	positive(v, "LOGIN_LOCKOUT_BASE", c.Auth.LockoutBase)
+	if c.Auth.LockoutMax < c.Auth.LockoutBase {
+		v.fail("LOGIN_LOCKOUT_MAX (%s) must not be shorter than LOGIN_LOCKOUT_BASE (%s)", c.Auth.LockoutMax, c.Auth.LockoutBase)
+	}
+	v.oneOf("LOGIN_LOCKOUT_FAILURE_POLICY", c.Auth.LockoutFailurePolicy, ratelimit.FailOpen, ratelimit.FailClosed)
+	v.require("TOTP_ISSUER", c.Auth.TOTPIssuer)
+	positive(v, "TWO_FACTOR_CHALLENGE_EXPIRY", c.Auth.ChallengeExpiry)
+
+	for name, provider := range c.OAuth.Providers {
+		if provider.IssuerURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			v.fail("OAuth provider %s: issuer URL, client ID and redirect URL are required", name)
+		}
+	}
+	positive(v, "OAUTH_STATE_TTL", c.OAuth.StateTTL)
+
+	positive(v, "REPORT_HIDE_THRESHOLD", c.Moderation.ReportHideThreshold)
+
+	nonNegative(v, "CONTENT_DUPLICATE_WINDOW", c.ContentFilter.DuplicateWindow)
+	nonNegative(v, "CONTENT_MAX_LINKS", c.ContentFilter.MaxLinks)
+	nonNegative(v, "CONTENT_MAX_LINK_DENSITY", c.ContentFilter.MaxLinkDensity)
+
+	v.oneOf("LOG_LEVEL", c.Log.Level, "debug", "info", "warn", "error")
+
+	if c.Tracing.Enabled {
+		v.require("TRACING_SERVICE_NAME", c.Tracing.ServiceName)
+		v.require("TRACING_OTLP_ENDPOINT", c.Tracing.Endpoint)
+	}
+	v.oneOf("TRACING_OTLP_PROTOCOL", c.Tracing.Protocol, "grpc", "http")
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.fail("TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	// Settings that can be left to defaults in development but not in production
+	if c.IsProduction() {
+		v.requireIn("production", "JWT_KEYS_DIR", c.JWT.KeysDir)
+		v.requireIn("production", "JWT_ACTIVE_KEY_ID", c.JWT.ActiveKeyID)
+		v.requireIn("production", "DB_PASSWORD", c.Database.Password)
+		v.requireIn("production", "REDIS_PASSWORD", c.Redis.Password)
+	}
+
+	if len(v.errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errs...))
+	}
+	return nil
+}
+
+// validator collects every failed check so they can be fixed in one go
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) require(key, value string) {
+	if value == "" {
+		v.fail("%s is required", key)
+	}
+}
+
+func (v *validator) requireIn(env, key, value string) {
+	if value == "" {
+		v.fail("%s is required in %s", key, env)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail("%s must be one of %q, got %q", key, allowed, value)
+}
+
+func (v *validator) port(key, value string) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		v.fail("%s must be a port number, got %q", key, value)
+	}
+}
+
+// number covers the numeric settings, including durations
+type number interface {
+	~int | ~int64 | ~float64
+}
+
+func positive[T number](v *validator, key string, value T) {
+	if value <= 0 {
+		v.fail("%s must be greater than zero, got %v", key, value)
+	}
+}
+
+func nonNegative[T number](v *validator, key string, value T) {
+	if value < 0 {
+		v.fail("%s must not be negative, got %v", key, value)
+	}
+}
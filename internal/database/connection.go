@@ -1,52 +1,195 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the pgx driver for database/sql
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+	"gorm.io/plugin/opentelemetry/tracing"
 
 	"social-media-app/internal/config"
+	"social-media-app/internal/logging"
+	"social-media-app/internal/metrics"
 	"social-media-app/internal/models"
 )
 
 var DB *gorm.DB
 
-// Connect establishes database connection
+// ReplicaResolver names the dbresolver configuration for read replicas. Reads
+// opt in with Clauses(dbresolver.Use(ReplicaResolver)); every other query,
+// including reads that must see the caller's own writes, goes to the primary.
+const ReplicaResolver = "replicas"
+
+// Connection pools of the read replicas, checked by PingReplicas
+var replicaPools []*sql.DB
+
+// Connect establishes the database connection, waiting for Postgres to come
+// up, and registers any read replicas
 func Connect(cfg *config.Config) error {
+	primary, err := openPool(cfg.Database, cfg.Database.Host, cfg.Database.Port)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	metrics.RegisterDBPool(primary, cfg.Database.DBName)
+
+	DB, err = gorm.Open(postgres.New(postgres.Config{Conn: primary}), &gorm.Config{
+		Logger: logging.NewGormLogger(cfg.Database.SlowQueryThreshold),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Record a span for every query, leaving out bound values which may hold user data
+	if err := DB.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables())); err != nil {
+		return fmt.Errorf("failed to enable query tracing: %w", err)
+	}
+
+	if len(cfg.Database.Replicas) > 0 {
+		replicas := make([]gorm.Dialector, 0, len(cfg.Database.Replicas))
+		for i, addr := range cfg.Database.Replicas {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return fmt.Errorf("invalid replica address %q: %w", addr, err)
+			}
+			pool, err := openPool(cfg.Database, host, port)
+			if err != nil {
+				return fmt.Errorf("failed to connect to replica %s: %w", addr, err)
+			}
+			metrics.RegisterDBPool(pool, fmt.Sprintf("%s_replica_%d", cfg.Database.DBName, i))
+			replicaPools = append(replicaPools, pool)
+			replicas = append(replicas, postgres.New(postgres.Config{Conn: pool}))
+		}
+
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		}, ReplicaResolver)
+		if err := DB.Use(resolver); err != nil {
+			return fmt.Errorf("failed to register read replicas: %w", err)
+		}
+		slog.Info("Read replicas registered", "replicas", len(replicas))
+	}
+
+	slog.Info("Database connected successfully")
+	return nil
+}
+
+// openPool opens a connection pool to one Postgres server and waits for it to
+// accept connections, backing off between attempts for up to
+// ConnectRetryTimeout so the app can start alongside the database
+func openPool(cfg config.DatabaseConfig, host, port string) (*sql.DB, error) {
 	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.DBName,
-		cfg.Database.SSLMode,
+		host,
+		port,
+		cfg.User,
+		cfg.Password,
+		cfg.DBName,
+		cfg.SSLMode,
 	)
+	if cfg.ConnectTimeout > 0 {
+		dsn += fmt.Sprintf(" connect_timeout=%d", int(cfg.ConnectTimeout.Seconds()))
+	}
+	// Passed to Postgres as a session setting on every connection
+	if cfg.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" statement_timeout=%d", cfg.StatementTimeout.Milliseconds())
+	}
 
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	pool, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	pool.SetMaxOpenConns(cfg.MaxOpenConns)
+	pool.SetMaxIdleConns(cfg.MaxIdleConns)
+	pool.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	pool.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	deadline := time.Now().Add(cfg.ConnectRetryTimeout)
+	backoff := connectBackoffMin
+	for attempt := 1; ; attempt++ {
+		err := pool.Ping()
+		if err == nil {
+			return pool, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			pool.Close()
+			return nil, fmt.Errorf("%s:%s not reachable after %d attempts: %w", host, port, attempt, err)
+		}
 
+		slog.Warn("Database not ready, retrying",
+			"host", host, "port", port, "attempt", attempt, "retry_in", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, connectBackoffMax)
+	}
+}
+
+// Delay between connection attempts at startup, doubling after each failure
+const (
+	connectBackoffMin = 500 * time.Millisecond
+	connectBackoffMax = 5 * time.Second
+)
+
+// Models managed by Migrate
+var migratedModels = []interface{}{
+	&models.User{},
+	&models.Post{},
+	&models.Like{},
+	&models.Follow{},
+	&models.RecoveryCode{},
+	&models.ExternalIdentity{},
+	&models.AuditLog{},
+	&models.Report{},
+	&schemaMigration{},
+}
+
+// Migrate runs database migrations, then any data migrations not yet applied
+func Migrate() error {
+	if err := DB.AutoMigrate(migratedModels...); err != nil {
+		return err
+	}
+	return runDataMigrations()
+}
+
+// Ping checks that a database connection can be used
+func Ping(ctx context.Context) error {
+	sqlDB, err := DB.DB()
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return err
 	}
+	return sqlDB.PingContext(ctx)
+}
 
-	log.Println("Database connected successfully")
+// PingReplicas checks that a connection to every read replica can be used
+func PingReplicas(ctx context.Context) error {
+	for i, pool := range replicaPools {
+		if err := pool.PingContext(ctx); err != nil {
+			return fmt.Errorf("replica %d: %w", i, err)
+		}
+	}
 	return nil
 }
 
-// Migrate runs database migrations
-func Migrate() error {
-	return DB.AutoMigrate(
-		&models.User{},
-		&models.Post{},
-		&models.Like{},
-		&models.Follow{},
-	)
+// CheckMigrations returns an error if the table for any model is missing or
+// a data migration hasn't been applied
+func CheckMigrations(ctx context.Context) error {
+	migrator := DB.WithContext(ctx).Migrator()
+	for _, model := range migratedModels {
+		if !migrator.HasTable(model) {
+			stmt := &gorm.Statement{DB: DB}
+			if err := stmt.Parse(model); err != nil {
+				return err
+			}
+			return fmt.Errorf("table %s has not been migrated", stmt.Schema.Table)
+		}
+	}
+	return checkDataMigrations(ctx)
 }
 
 // AddConstraints adds custom database constraints
@@ -57,7 +200,7 @@ func AddConstraints() error {
 		ADD CONSTRAINT unique_user_post_like 
 		UNIQUE (user_id, post_id)
 	`).Error; err != nil {
-		log.Printf("Constraint already exists or error: %v", err)
+		slog.Warn("Constraint already exists or failed to create", "error", err)
 	}
 
 	// Add unique constraint for follows (user can only follow another user once)
@@ -66,7 +209,7 @@ func AddConstraints() error {
 		ADD CONSTRAINT unique_follower_following 
 		UNIQUE (follower_id, following_id)
 	`).Error; err != nil {
-		log.Printf("Constraint already exists or error: %v", err)
+		slog.Warn("Constraint already exists or failed to create", "error", err)
 	}
 
 	// Add check constraint to prevent self-following
@@ -75,7 +218,27 @@ func AddConstraints() error {
 		ADD CONSTRAINT no_self_follow 
 		CHECK (follower_id != following_id)
 	`).Error; err != nil {
-		log.Printf("Constraint already exists or error: %v", err)
+		slog.Warn("Constraint already exists or failed to create", "error", err)
+	}
+
+	// Make the audit log append-only
+	if err := DB.Exec(`
+		CREATE OR REPLACE FUNCTION prevent_audit_log_changes()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_logs is append-only';
+		END;
+		$$ LANGUAGE plpgsql
+	`).Error; err != nil {
+		slog.Error("Failed to create audit log trigger function", "error", err)
+	}
+
+	if err := DB.Exec(`
+		CREATE OR REPLACE TRIGGER audit_logs_append_only
+		BEFORE UPDATE OR DELETE ON audit_logs
+		FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_changes()
+	`).Error; err != nil {
+		slog.Error("Failed to create audit log trigger", "error", err)
 	}
 
 	return nil
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// dataMigration is a one-time change to existing rows. Each is applied in
+// order, in its own transaction, and recorded in schema_migrations so it
+// never runs again.
+type dataMigration struct {
+	Version    string
+	Statements []string
+}
+
+// dataMigrations must only ever be appended to
+var dataMigrations = []dataMigration{
+	{
+		// Unlikes used to be soft deletes, which left rows blocking the post
+		// from being liked again
+		Version: "0001_remove_soft_deleted_likes",
+		Statements: []string{
+			`DELETE FROM likes WHERE deleted_at IS NOT NULL`,
+		},
+	},
+	{
+		// Like counts are maintained by the like repository; bring posts
+		// liked before that up to date. Likes are locked against writes so
+		// none are missed while counting.
+		Version: "0002_backfill_post_like_counts",
+		Statements: []string{
+			`LOCK TABLE likes IN SHARE MODE`,
+			`UPDATE posts SET like_count = counts.total
+			FROM (SELECT post_id, COUNT(*) AS total FROM likes WHERE deleted_at IS NULL GROUP BY post_id) AS counts
+			WHERE posts.id = counts.post_id AND posts.like_count IS DISTINCT FROM counts.total`,
+		},
+	},
+	{
+		// Follow and post counts are maintained by the follow and post
+		// repositories; bring users from before that up to date. Follows and
+		// posts are locked against writes, so no change lands between being
+		// counted and the counter being set.
+		Version: "0003_backfill_user_counts",
+		Statements: []string{
+			`LOCK TABLE follows, posts IN SHARE MODE`,
+			`UPDATE users SET
+				follower_count = COALESCE(followers.total, 0),
+				following_count = COALESCE(following.total, 0),
+				post_count = COALESCE(posts.total, 0)
+			FROM users u
+			LEFT JOIN (SELECT following_id AS user_id, COUNT(*) AS total FROM follows WHERE deleted_at IS NULL GROUP BY following_id) AS followers
+				ON followers.user_id = u.id
+			LEFT JOIN (SELECT follower_id AS user_id, COUNT(*) AS total FROM follows WHERE deleted_at IS NULL GROUP BY follower_id) AS following
+				ON following.user_id = u.id
+			LEFT JOIN (SELECT user_id, COUNT(*) AS total FROM posts WHERE deleted_at IS NULL GROUP BY user_id) AS posts
+				ON posts.user_id = u.id
+			WHERE users.id = u.id`,
+		},
+	},
+}
+
+// schemaMigration records an applied data migration
+type schemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Advisory lock held while applying a data migration, so instances starting
+// together apply each one once
+const migrationLockID = 7_412_031
+
+// runDataMigrations applies the data migrations that haven't been recorded yet
+func runDataMigrations() error {
+	for _, migration := range dataMigrations {
+		applied := false
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, migrationLockID).Error; err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&schemaMigration{}).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			for _, statement := range migration.Statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			applied = true
+			return tx.Create(&schemaMigration{Version: migration.Version, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("data migration %s: %w", migration.Version, err)
+		}
+		if applied {
+			slog.Info("Applied data migration", "version", migration.Version)
+		}
+	}
+	return nil
+}
+
+// checkDataMigrations returns an error if any data migration hasn't been applied
+func checkDataMigrations(ctx context.Context) error {
+	var applied []string
+	if err := DB.WithContext(ctx).Model(&schemaMigration{}).Pluck("version", &applied).Error; err != nil {
+		return err
+	}
+
+	done := make(map[string]bool, len(applied))
+	for _, version := range applied {
+		done[version] = true
+	}
+	for _, migration := range dataMigrations {
+		if !done[migration.Version] {
+			return fmt.Errorf("data migration %s has not been applied", migration.Version)
+		}
+	}
+	return nil
+}
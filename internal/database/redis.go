@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+
+	"social-media-app/internal/circuitbreaker"
+	"social-media-app/internal/config"
+	"social-media-app/internal/metrics"
+	"social-media-app/internal/tracing"
+)
+
+// NewRedisClient connects to Redis with tracing, pool metrics and a circuit
+// breaker. Once the breaker opens, commands fail with circuitbreaker.ErrOpen
+// straight away so callers can fall back without waiting on timeouts.
+func NewRedisClient(cfg *config.Config, name string) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Redis.Host + ":" + cfg.Redis.Port,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		PoolSize:     cfg.Redis.PoolSize,
+		DialTimeout:  cfg.Redis.DialTimeout,
+		ReadTimeout:  cfg.Redis.ReadTimeout,
+		WriteTimeout: cfg.Redis.WriteTimeout,
+	})
+
+	breaker := circuitbreaker.New("redis_"+name, cfg.Redis.BreakerThreshold, cfg.Redis.BreakerCooldown)
+	breaker.OnStateChange(func(name string, state circuitbreaker.State) {
+		metrics.CircuitBreakerState.WithLabelValues(name).Set(float64(state))
+	})
+	metrics.CircuitBreakerState.WithLabelValues("redis_" + name).Set(float64(circuitbreaker.StateClosed))
+	client.AddHook(breakerHook{breaker: breaker})
+
+	tracing.InstrumentRedis(client)
+	metrics.RegisterRedisPool(name, client)
+
+	return client
+}
+
+// breakerHook runs every command and pipeline through the circuit breaker
+type breakerHook struct {
+	breaker *circuitbreaker.Breaker
+}
+
+func (h breakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h breakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := h.breaker.Allow(); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err := next(ctx, cmd)
+		h.breaker.Record(isOutage(err))
+		return err
+	}
+}
+
+func (h breakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := h.breaker.Allow(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err := next(ctx, cmds)
+		h.breaker.Record(isOutage(err))
+		return err
+	}
+}
+
+// isOutage reports whether err means Redis could not be used, as opposed to
+// a missing key, an error reply or the caller giving up
+func isOutage(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var redisErr redis.Error
+	return !errors.As(err, &redisErr)
+}
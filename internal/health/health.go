@@ -0,0 +1,127 @@
+// Package health reports whether the API and the services it depends on are
+// able to serve traffic
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+	StatusDraining = "draining"
+)
+
+// CheckFunc returns an error if a dependency cannot be used
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of a single dependency check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the readiness of the API along with each dependency check
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker runs the registered dependency checks. Once draining it reports the
+// API as not ready so load balancers stop sending new requests before shutdown.
+type Checker struct {
+	mu       sync.RWMutex
+	checks   map[string]CheckFunc
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		checks:  make(map[string]CheckFunc),
+		timeout: timeout,
+	}
+}
+
+// Register adds a dependency check, replacing any check with the same name
+func (h *Checker) Register(name string, check CheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// StartDraining marks the API as shutting down
+func (h *Checker) StartDraining() {
+	h.draining.Store(true)
+}
+
+// Ready runs every check concurrently and reports whether all of them passed
+func (h *Checker) Ready(ctx context.Context) (Report, bool) {
+	h.mu.RLock()
+	checks := make(map[string]CheckFunc, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mu.RUnlock()
+
+	report := Report{Checks: make(map[string]CheckResult, len(checks))}
+
+	var (
+		wg        sync.WaitGroup
+		resultsMu sync.Mutex
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+			result := h.run(ctx, check)
+
+			resultsMu.Lock()
+			report.Checks[name] = result
+			resultsMu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range report.Checks {
+		if result.Status != StatusUp {
+			ready = false
+		}
+	}
+
+	switch {
+	case h.draining.Load():
+		report.Status = StatusDraining
+		ready = false
+	case ready:
+		report.Status = StatusReady
+	default:
+		report.Status = StatusNotReady
+	}
+
+	return report, ready
+}
+
+func (h *Checker) run(ctx context.Context, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+// Package logging provides the structured logger and carries it in request contexts
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"social-media-app/internal/config"
+)
+
+type contextKey struct{}
+
+// New builds the JSON logger and installs it as the default, so code still
+// using the standard log package is written in the same format
+func New(cfg config.LogConfig) *slog.Logger {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: ParseLevel(cfg.Level),
+	}))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ParseLevel accepts debug, info, warn or error and defaults to info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithLogger returns a context carrying the logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the request's logger, which includes the request ID,
+// or the default logger outside of a request
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,153 @@
+// Package metrics holds the Prometheus metrics for application events and
+// the pools of the services the API depends on
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// Counter for posts created, by whether the content filters hid them
+	PostsCreated = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "posts_created_total",
+			Help: "Total number of posts created",
+		},
+		[]string{"visibility"},
+	)
+
+	// Counter for likes added and removed
+	Likes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "likes_total",
+			Help: "Total number of posts liked and unliked",
+		},
+		[]string{"action"},
+	)
+
+	// Counter for follows added and removed
+	Follows = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "follows_total",
+			Help: "Total number of users followed and unfollowed",
+		},
+		[]string{"action"},
+	)
+
+	// Counter for new accounts, by how the user signed up
+	Signups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "signups_total",
+			Help: "Total number of user accounts created",
+		},
+		[]string{"method"},
+	)
+
+	// Counter for cache lookups, by cache and whether the entry was found
+	CacheRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_requests_total",
+			Help: "Total number of cache lookups",
+		},
+		[]string{"cache", "result"},
+	)
+
+	// Counter for requests turned away by the rate limiter
+	RateLimitRejections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rate_limit_rejections_total",
+			Help: "Total number of requests rejected by the rate limiter",
+		},
+		[]string{"action"},
+	)
+
+	// Counter for rate limit checks that could not reach the store, by the failure policy applied
+	RateLimitStoreErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rate_limit_store_errors_total",
+			Help: "Total number of rate limit checks made while the rate limit store was unavailable",
+		},
+		[]string{"action", "policy"},
+	)
+
+	// Gauge for circuit breaker state: 0 closed, 1 open, 2 half open
+	CircuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "State of each circuit breaker (0 closed, 1 open, 2 half open)",
+		},
+		[]string{"name"},
+	)
+)
+
+// Label values
+const (
+	ActionLike     = "like"
+	ActionUnlike   = "unlike"
+	ActionFollow   = "follow"
+	ActionUnfollow = "unfollow"
+
+	CacheTimeline = "timeline"
+	CachePost     = "post"
+)
+
+// CacheLookup records whether a cache lookup found an entry
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// RegisterDBPool exports the connection pool statistics of db as go_sql_* metrics
+func RegisterDBPool(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterRedisPool exports the connection pool statistics of a Redis client,
+// labelled with the client's name
+func RegisterRedisPool(name string, client *redis.Client) {
+	labels := prometheus.Labels{"client": name}
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "redis_pool_total_connections",
+		Help:        "Number of connections in the Redis pool",
+		ConstLabels: labels,
+	}, func() float64 { return float64(client.PoolStats().TotalConns) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "redis_pool_idle_connections",
+		Help:        "Number of idle connections in the Redis pool",
+		ConstLabels: labels,
+	}, func() float64 { return float64(client.PoolStats().IdleConns) })
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name:        "redis_pool_hits_total",
+		Help:        "Number of times a free connection was found in the Redis pool",
+		ConstLabels: labels,
+	}, func() float64 { return float64(client.PoolStats().Hits) })
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name:        "redis_pool_misses_total",
+		Help:        "Number of times a new connection had to be opened for the Redis pool",
+		ConstLabels: labels,
+	}, func() float64 { return float64(client.PoolStats().Misses) })
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name:        "redis_pool_timeouts_total",
+		Help:        "Number of times waiting for a Redis pool connection timed out",
+		ConstLabels: labels,
+	}, func() float64 { return float64(client.PoolStats().Timeouts) })
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name:        "redis_pool_stale_connections_total",
+		Help:        "Number of stale connections removed from the Redis pool",
+		ConstLabels: labels,
+	}, func() float64 { return float64(client.PoolStats().StaleConns) })
+}
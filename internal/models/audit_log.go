@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog records a security-sensitive or moderation action. Rows are only
+// ever inserted; a database trigger rejects updates and deletes.
+type AuditLog struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	ActorID    uint            `json:"actor_id" gorm:"not null;index"` // 0 for anonymous requests
+	Action     string          `json:"action" gorm:"not null;size:50;index"`
+	TargetType string          `json:"target_type" gorm:"size:50;index:idx_audit_logs_target"`
+	TargetID   uint            `json:"target_id" gorm:"index:idx_audit_logs_target"`
+	IPAddress  string          `json:"ip_address" gorm:"size:45"`
+	RequestID  string          `json:"request_id" gorm:"size:64;index"`
+	Changes    json.RawMessage `json:"changes,omitempty" gorm:"type:jsonb"`
+	CreatedAt  time.Time       `json:"created_at" gorm:"index"`
+}
+
+// TableName specifies the table name
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// AuditChange is the before and after value of a single field
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditLogFilter narrows an audit log query. Zero values are ignored.
+type AuditLogFilter struct {
+	ActorID    uint      `form:"actor_id"`
+	Action     string    `form:"action"`
+	TargetType string    `form:"target_type"`
+	TargetID   uint      `form:"target_id"`
+	RequestID  string    `form:"request_id"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// RequestMeta describes who made a request and from where, for auditing
+type RequestMeta struct {
+	ActorID   uint
+	ClientIP  string
+	RequestID string
+}
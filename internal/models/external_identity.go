@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// ExternalIdentity links a user to an account at an OpenID Connect provider
+type ExternalIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"not null;size:50;uniqueIndex:unique_provider_subject"`
+	Subject   string    `json:"-" gorm:"not null;size:255;uniqueIndex:unique_provider_subject"`
+	Email     string    `json:"email" gorm:"size:255"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// TableName specifies the table name
+func (ExternalIdentity) TableName() string {
+	return "external_identities"
+}
+
+// OAuthState is what is remembered between redirecting to a provider and its callback
+type OAuthState struct {
+	Provider     string `json:"provider"`
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+}
+
+// OAuthCallbackRequest is the query string a provider redirects back with
+type OAuthCallbackRequest struct {
+	Code             string `form:"code"`
+	State            string `form:"state" binding:"required"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
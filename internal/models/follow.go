@@ -28,8 +28,11 @@ type FollowRequest struct {
 	UserID uint `json:"user_id" binding:"required"`
 }
 
-// FollowResponse represents follow relationship info
+// FollowResponse represents follow relationship info. FollowsYou and
+// YouFollow are relative to the user viewing the list.
 type FollowResponse struct {
-	User      UserResponse `json:"user"`
-	CreatedAt time.Time    `json:"created_at"`
+	User       UserResponse `json:"user"`
+	CreatedAt  time.Time    `json:"created_at"`
+	FollowsYou bool         `json:"follows_you"`
+	YouFollow  bool         `json:"you_follow"`
 }
@@ -12,6 +12,7 @@ type Post struct {
 	Content   string         `json:"content" gorm:"not null;size:1000"`
 	ImageURL  string         `json:"image_url" gorm:"size:255"`
 	LikeCount int            `json:"like_count" gorm:"default:0"`
+	IsHidden  bool           `json:"is_hidden" gorm:"default:false;index"` // Hidden pending moderation review
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -30,6 +31,7 @@ type PostResponse struct {
 	CreatedAt time.Time    `json:"created_at"`
 	User      UserResponse `json:"user"`
 	IsLiked   bool         `json:"is_liked"` // Whether current user liked this post
+	IsHidden  bool         `json:"is_hidden,omitempty"`
 }
 
 // CreatePostRequest represents the request to create a new post
@@ -54,5 +56,6 @@ func (p *Post) ToResponse() PostResponse {
 		CreatedAt: p.CreatedAt,
 		User:      p.User.ToResponse(),
 		IsLiked:   false, // This will be set by the service layer
+		IsHidden:  p.IsHidden,
 	}
 }
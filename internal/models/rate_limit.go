@@ -0,0 +1,12 @@
+package models
+
+// RateLimitStatus is the state of one rate limited action for a user
+type RateLimitStatus struct {
+	Action            string `json:"action"`
+	Exempt            bool   `json:"exempt"`
+	Algorithm         string `json:"algorithm,omitempty"`
+	Limit             int    `json:"limit"`
+	WindowSeconds     int64  `json:"window_seconds"`
+	Remaining         int    `json:"remaining"`
+	ResetAfterSeconds int64  `json:"reset_after_seconds"`
+}
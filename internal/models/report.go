@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+)
+
+// ReportTargetType is the kind of content a report is filed against
+type ReportTargetType string
+
+const (
+	ReportTargetPost ReportTargetType = "post"
+	ReportTargetUser ReportTargetType = "user"
+)
+
+// ReportReason is a machine-readable reason code
+type ReportReason string
+
+const (
+	ReportReasonSpam           ReportReason = "spam"
+	ReportReasonHarassment     ReportReason = "harassment"
+	ReportReasonHateSpeech     ReportReason = "hate_speech"
+	ReportReasonViolence       ReportReason = "violence"
+	ReportReasonSexualContent  ReportReason = "sexual_content"
+	ReportReasonMisinformation ReportReason = "misinformation"
+	ReportReasonImpersonation  ReportReason = "impersonation"
+	ReportReasonOther          ReportReason = "other"
+)
+
+// ReportStatus tracks a report through the moderation queue
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "open"
+	ReportStatusResolved  ReportStatus = "resolved"
+	ReportStatusDismissed ReportStatus = "dismissed"
+)
+
+type Report struct {
+	ID             uint             `json:"id" gorm:"primaryKey"`
+	ReporterID     uint             `json:"reporter_id" gorm:"not null;index"`
+	TargetType     ReportTargetType `json:"target_type" gorm:"not null;size:20;index:idx_reports_target"`
+	TargetID       uint             `json:"target_id" gorm:"not null;index:idx_reports_target"`
+	Reason         ReportReason     `json:"reason" gorm:"not null;size:30"`
+	Details        string           `json:"details" gorm:"size:1000"`
+	Status         ReportStatus     `json:"status" gorm:"not null;size:20;default:open;index"`
+	AssigneeID     *uint            `json:"assignee_id" gorm:"index"`
+	ResolvedByID   *uint            `json:"resolved_by_id"`
+	ResolutionNote string           `json:"resolution_note" gorm:"size:1000"`
+	ResolvedAt     *time.Time       `json:"resolved_at"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// TableName specifies the table name
+func (Report) TableName() string {
+	return "reports"
+}
+
+// CreateReportRequest represents a user flagging a post or account
+type CreateReportRequest struct {
+	TargetType ReportTargetType `json:"target_type" binding:"required,oneof=post user"`
+	TargetID   uint             `json:"target_id" binding:"required"`
+	Reason     ReportReason     `json:"reason" binding:"required,oneof=spam harassment hate_speech violence sexual_content misinformation impersonation other"`
+	Details    string           `json:"details" binding:"max=1000"`
+}
+
+// AssignReportRequest assigns a report to a moderator, defaulting to the caller
+type AssignReportRequest struct {
+	AssigneeID uint `json:"assignee_id"`
+}
+
+// ResolveReportRequest closes a report. RemoveContent deletes a reported post.
+type ResolveReportRequest struct {
+	Note          string `json:"note" binding:"max=1000"`
+	RemoveContent bool   `json:"remove_content"`
+}
+
+// DismissReportRequest closes a report without action
+type DismissReportRequest struct {
+	Note string `json:"note" binding:"max=1000"`
+}
+
+// ReportFilter narrows the moderation queue. Zero values are ignored.
+type ReportFilter struct {
+	Status     ReportStatus     `form:"status"`
+	TargetType ReportTargetType `form:"target_type"`
+	AssigneeID uint             `form:"assignee_id"`
+	Unassigned bool             `form:"unassigned"`
+}
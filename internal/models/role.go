@@ -0,0 +1,58 @@
+package models
+
+// Role determines what a user is allowed to do beyond managing their own content
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Permission is a single privileged capability granted by a role
+type Permission string
+
+const (
+	PermissionDeleteAnyPost Permission = "posts:delete_any"
+	PermissionManageUsers   Permission = "users:manage"
+	PermissionManageRoles   Permission = "users:manage_roles"
+	PermissionViewAuditLog  Permission = "audit:view"
+	PermissionModerate      Permission = "reports:moderate"
+)
+
+// rolePermissions maps each role to the permissions it grants
+var rolePermissions = map[Role][]Permission{
+	RoleUser: {},
+	RoleModerator: {
+		PermissionDeleteAnyPost,
+		PermissionModerate,
+	},
+	RoleAdmin: {
+		PermissionDeleteAnyPost,
+		PermissionModerate,
+		PermissionManageUsers,
+		PermissionManageRoles,
+		PermissionViewAuditLog,
+	},
+}
+
+// IsValid reports whether r is a known role
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// HasPermission reports whether the role grants the permission
+func (r Role) HasPermission(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateRoleRequest changes a user's role
+type UpdateRoleRequest struct {
+	Role Role `json:"role" binding:"required,oneof=user moderator admin"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a hashed single-use code for logging in without the TOTP device
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+// TwoFactorEnrollResponse contains what an authenticator app needs to be set up
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorActivateRequest confirms enrollment with a code from the authenticator app
+type TwoFactorActivateRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// TwoFactorActivateResponse returns the recovery codes, which are only shown once
+type TwoFactorActivateResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorDisableRequest requires the current password and a second factor
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorVerifyRequest completes a login that returned a challenge token.
+// Code may be either a TOTP code or an unused recovery code.
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
@@ -16,10 +16,22 @@ type User struct {
 	Bio       string         `json:"bio" gorm:"size:500"`
 	Avatar    string         `json:"avatar" gorm:"size:255"`
 	IsActive  bool           `json:"is_active" gorm:"default:true"`
+	Role      Role           `json:"role" gorm:"size:20;not null;default:user"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// Two-factor authentication
+	TOTPSecret       string `json:"-" gorm:"size:64"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled" gorm:"default:false"`
+	// Last TOTP time step accepted, so a code can't be used twice
+	TOTPLastStep int64 `json:"-" gorm:"not null;default:0"`
+
+	// Counters maintained by the follow and post repositories
+	FollowerCount  int `json:"follower_count" gorm:"not null;default:0"`
+	FollowingCount int `json:"following_count" gorm:"not null;default:0"`
+	PostCount      int `json:"post_count" gorm:"not null;default:0"`
+
 	// Relationships
 	Posts     []Post   `json:"posts,omitempty" gorm:"foreignKey:UserID"`
 	Likes     []Like   `json:"likes,omitempty" gorm:"foreignKey:UserID"`
@@ -36,9 +48,29 @@ type UserResponse struct {
 	LastName  string    `json:"last_name"`
 	Bio       string    `json:"bio"`
 	Avatar    string    `json:"avatar"`
+	Role      Role      `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// ProfileResponse is a user's profile with their counts and, when viewed by
+// someone else, how the viewer and the user are connected
+type ProfileResponse struct {
+	UserResponse
+	FollowerCount  int  `json:"follower_count"`
+	FollowingCount int  `json:"following_count"`
+	PostCount      int  `json:"post_count"`
+	FollowsYou     bool `json:"follows_you"`
+	YouFollow      bool `json:"you_follow"`
+}
+
+// AdminUserResponse adds account state that only administrators can see
+type AdminUserResponse struct {
+	UserResponse
+	IsActive         bool      `json:"is_active"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
 // ToResponse converts User to UserResponse
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
@@ -49,10 +81,37 @@ func (u *User) ToResponse() UserResponse {
 		LastName:  u.LastName,
 		Bio:       u.Bio,
 		Avatar:    u.Avatar,
+		Role:      u.Role,
 		CreatedAt: u.CreatedAt,
 	}
 }
 
+// HasPermission reports whether the user's role grants the permission
+func (u *User) HasPermission(permission Permission) bool {
+	return u.Role.HasPermission(permission)
+}
+
+// ToAdminResponse converts User to AdminUserResponse
+func (u *User) ToAdminResponse() AdminUserResponse {
+	return AdminUserResponse{
+		UserResponse:     u.ToResponse(),
+		IsActive:         u.IsActive,
+		TwoFactorEnabled: u.TwoFactorEnabled,
+		UpdatedAt:        u.UpdatedAt,
+	}
+}
+
+// ToProfileResponse converts User to ProfileResponse. The follow flags are
+// left for the caller to fill in for the viewer.
+func (u *User) ToProfileResponse() ProfileResponse {
+	return ProfileResponse{
+		UserResponse:   u.ToResponse(),
+		FollowerCount:  u.FollowerCount,
+		FollowingCount: u.FollowingCount,
+		PostCount:      u.PostCount,
+	}
+}
+
 // RegisterRequest represents user registration data
 type RegisterRequest struct {
 	Username  string `json:"username" binding:"required,min=3,max=50"`
@@ -68,8 +127,12 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse represents the response after successful login
+// LoginResponse represents the response after successful login.
+// When two-factor authentication is enabled only ChallengeToken is set
+// and the client must complete the login with a TOTP or recovery code.
 type LoginResponse struct {
-	User  UserResponse `json:"user"`
-	Token string       `json:"token"`
+	User              *UserResponse `json:"user,omitempty"`
+	Token             string        `json:"token,omitempty"`
+	RequiresTwoFactor bool          `json:"requires_two_factor,omitempty"`
+	ChallengeToken    string        `json:"challenge_token,omitempty"`
 }
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often expired windows are swept from the local store
+const localSweepInterval = time.Minute
+
+// LocalStore is an in-process fixed window limiter used while Redis is
+// unavailable. Every algorithm is approximated by a fixed window, and counts
+// are per instance, so the effective limit across instances is higher than
+// configured.
+type LocalStore struct {
+	mu        sync.Mutex
+	windows   map[string]*localWindow
+	lastSweep time.Time
+}
+
+type localWindow struct {
+	end   time.Time
+	count int
+}
+
+func NewLocalStore() *LocalStore {
+	return &LocalStore{
+		windows:   make(map[string]*localWindow),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *LocalStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return s.count(key, limit, false), nil
+}
+
+func (s *LocalStore) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	return s.count(key, limit, true), nil
+}
+
+func (s *LocalStore) Reset(ctx context.Context, prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.windows {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.windows, key)
+		}
+	}
+	return nil
+}
+
+func (s *LocalStore) count(key string, limit Limit, peek bool) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= localSweepInterval {
+		s.sweep(now)
+	}
+
+	// Keyed like RedisStore so Reset prefixes match both stores
+	key += ":" + string(limit.Algorithm)
+	window, ok := s.windows[key]
+	if !ok || !now.Before(window.end) {
+		window = &localWindow{end: now.Add(limit.Window)}
+		if !peek {
+			s.windows[key] = window
+		}
+	}
+
+	result := Result{
+		Limit:      limit.Requests,
+		Remaining:  limit.Requests - window.count,
+		ResetAfter: window.end.Sub(now),
+	}
+	if window.count >= limit.Requests {
+		result.Remaining = 0
+		result.RetryAfter = result.ResetAfter
+		return result
+	}
+
+	result.Allowed = true
+	if !peek {
+		window.count++
+		result.Remaining--
+	}
+	return result
+}
+
+func (s *LocalStore) sweep(now time.Time) {
+	for key, window := range s.windows {
+		if !now.Before(window.end) {
+			delete(s.windows, key)
+		}
+	}
+	s.lastSweep = now
+}
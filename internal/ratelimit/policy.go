@@ -0,0 +1,271 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Failure policies, applied when the rate limit store is unavailable
+const (
+	// FailOpen lets requests through, limited per instance by an in-process limiter
+	FailOpen = "open"
+	// FailClosed rejects requests
+	FailClosed = "closed"
+)
+
+// Policy is the limit for an action. Zero fields inherit from the default
+// policy, and Roles override the limit for users with a given role.
+type Policy struct {
+	Requests      int               `yaml:"requests"`
+	Window        time.Duration     `yaml:"window"`
+	Algorithm     Algorithm         `yaml:"algorithm"`
+	FailurePolicy string            `yaml:"failure_policy"`
+	Exempt        bool              `yaml:"exempt"`
+	Roles         map[string]Policy `yaml:"roles"`
+}
+
+// PolicySet holds the default policy, the per action policies and the users
+// that are never rate limited
+type PolicySet struct {
+	Default       Policy            `yaml:"default"`
+	Actions       map[string]Policy `yaml:"actions"`
+	ExemptUserIDs []uint            `yaml:"exempt_user_ids"`
+}
+
+// Resolved is the policy that applies to one request
+type Resolved struct {
+	Limit         Limit
+	FailurePolicy string
+	Exempt        bool
+}
+
+// Resolve works out the policy for a request to action by a user with the
+// given role. Anonymous requests pass an empty role and a zero user ID.
+func (s *PolicySet) Resolve(action, role string, userID uint) Resolved {
+	if userID != 0 && slices.Contains(s.ExemptUserIDs, userID) {
+		return Resolved{Exempt: true}
+	}
+
+	policy := s.Default
+	if tier, ok := s.Default.Roles[role]; ok {
+		policy = overlay(policy, tier)
+	}
+	if actionPolicy, ok := s.Actions[action]; ok {
+		policy = overlay(policy, actionPolicy)
+		if tier, ok := actionPolicy.Roles[role]; ok {
+			policy = overlay(policy, tier)
+		}
+	}
+
+	return Resolved{
+		Limit: Limit{
+			Requests:  policy.Requests,
+			Window:    policy.Window,
+			Algorithm: policy.Algorithm,
+		},
+		FailurePolicy: policy.FailurePolicy,
+		Exempt:        policy.Exempt,
+	}
+}
+
+// overlay returns base with the fields set in override replaced. Role tiers
+// are merged so an action can override a single role's limit.
+func overlay(base, override Policy) Policy {
+	if override.Requests > 0 {
+		base.Requests = override.Requests
+	}
+	if override.Window > 0 {
+		base.Window = override.Window
+	}
+	if override.Algorithm != "" {
+		base.Algorithm = override.Algorithm
+	}
+	if override.FailurePolicy != "" {
+		base.FailurePolicy = override.FailurePolicy
+	}
+	if override.Exempt {
+		base.Exempt = true
+	}
+	if len(override.Roles) > 0 {
+		roles := make(map[string]Policy, len(base.Roles)+len(override.Roles))
+		for role, tier := range base.Roles {
+			roles[role] = tier
+		}
+		for role, tier := range override.Roles {
+			roles[role] = overlay(roles[role], tier)
+		}
+		base.Roles = roles
+	}
+	return base
+}
+
+// Merge returns the policies in s overridden by those in override
+func (s *PolicySet) Merge(override *PolicySet) *PolicySet {
+	merged := &PolicySet{
+		Default:       overlay(s.Default, override.Default),
+		Actions:       make(map[string]Policy, len(s.Actions)+len(override.Actions)),
+		ExemptUserIDs: s.ExemptUserIDs,
+	}
+	for action, policy := range s.Actions {
+		merged.Actions[action] = policy
+	}
+	for action, policy := range override.Actions {
+		merged.Actions[action] = overlay(merged.Actions[action], policy)
+	}
+	if override.ExemptUserIDs != nil {
+		merged.ExemptUserIDs = override.ExemptUserIDs
+	}
+	return merged
+}
+
+// Validate checks that every policy uses known algorithms and failure
+// policies, and that the default policy sets a limit
+func (s *PolicySet) Validate() error {
+	if s.Default.Requests <= 0 || s.Default.Window <= 0 {
+		return fmt.Errorf("default policy must set requests and window")
+	}
+	if err := validatePolicy("default", s.Default); err != nil {
+		return err
+	}
+	for action, policy := range s.Actions {
+		if err := validatePolicy(action, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePolicy(name string, policy Policy) error {
+	if policy.Requests < 0 || policy.Window < 0 {
+		return fmt.Errorf("policy %s: requests and window must not be negative", name)
+	}
+	if policy.Algorithm != "" {
+		if _, err := ParseAlgorithm(string(policy.Algorithm)); err != nil {
+			return fmt.Errorf("policy %s: %w", name, err)
+		}
+	}
+	if policy.FailurePolicy != "" && policy.FailurePolicy != FailOpen && policy.FailurePolicy != FailClosed {
+		return fmt.Errorf("policy %s: failure_policy must be %q or %q", name, FailOpen, FailClosed)
+	}
+	for role, tier := range policy.Roles {
+		if err := validatePolicy(name+"."+role, tier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadPolicyFile reads a YAML policy file
+func LoadPolicyFile(path string) (*PolicySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown keys are rejected so a misspelt setting doesn't silently fall
+	// back to the default
+	var set PolicySet
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&set); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &set, nil
+}
+
+// Policies holds the policies in effect. Policies read from the file are
+// layered over the built-in defaults and reloaded when the file changes.
+type Policies struct {
+	defaults *PolicySet
+	path     string
+	current  atomic.Pointer[PolicySet]
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewPolicies applies the policy file at path, if any, over defaults
+func NewPolicies(defaults *PolicySet, path string) (*Policies, error) {
+	if err := defaults.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &Policies{defaults: defaults, path: path}
+	p.current.Store(defaults)
+	if path == "" {
+		return p, nil
+	}
+
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Current returns the policies in effect
+func (p *Policies) Current() *PolicySet {
+	return p.current.Load()
+}
+
+// Reload re-reads the policy file if it has changed since it was last read.
+// Invalid files are rejected and the previous policies stay in effect.
+func (p *Policies) Reload() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(p.modTime) {
+		return false, nil
+	}
+
+	override, err := LoadPolicyFile(p.path)
+	if err != nil {
+		return false, err
+	}
+	merged := p.defaults.Merge(override)
+	if err := merged.Validate(); err != nil {
+		return false, fmt.Errorf("invalid rate limit policies in %s: %w", p.path, err)
+	}
+
+	p.current.Store(merged)
+	p.modTime = info.ModTime()
+	return true, nil
+}
+
+// Watch checks the policy file for changes every interval until ctx is done
+func (p *Policies) Watch(ctx context.Context, interval time.Duration) {
+	if p.path == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := p.Reload()
+			if err != nil {
+				slog.Error("Failed to reload rate limit policies", "path", p.path, "error", err)
+			} else if reloaded {
+				slog.Info("Reloaded rate limit policies", "path", p.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package ratelimit counts requests against limits using one of several
+// algorithms, with state kept in Redis or in process
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Algorithm selects how requests are counted against a limit
+type Algorithm string
+
+const (
+	// SlidingWindow allows Requests in any Window long period
+	SlidingWindow Algorithm = "sliding_window"
+	// TokenBucket allows bursts of Requests, refilled evenly over Window
+	TokenBucket Algorithm = "token_bucket"
+	// GCRA spaces requests evenly over Window while allowing a burst of Requests
+	GCRA Algorithm = "gcra"
+)
+
+// ParseAlgorithm returns the algorithm with the given name
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch algorithm := Algorithm(name); algorithm {
+	case SlidingWindow, TokenBucket, GCRA:
+		return algorithm, nil
+	}
+	return "", fmt.Errorf("unknown rate limit algorithm %q", name)
+}
+
+// Limit is the number of requests allowed per window
+type Limit struct {
+	Requests  int
+	Window    time.Duration
+	Algorithm Algorithm
+}
+
+// Result is the outcome of counting a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is how long until the full limit is available again
+	ResetAfter time.Duration
+	// RetryAfter is how long until the next request would be allowed, zero if allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the count of requests made against each key
+type Store interface {
+	// Allow counts a request against the limit for key
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	// Peek reports the state of the limit for key without counting a request
+	Peek(ctx context.Context, key string, limit Limit) (Result, error)
+	// Reset clears the counts for every key starting with prefix
+	Reset(ctx context.Context, prefix string) error
+}
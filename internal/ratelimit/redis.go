@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Each script checks and updates the limit atomically using the Redis clock
+// and returns {allowed, remaining, reset after ms, retry after ms}. When
+// ARGV[3] is 1 the limit is only inspected and no request is counted.
+
+// slidingWindowScript keeps one sorted set member per request, scored by time
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local peek = ARGV[3] == '1'
+
+local allowed = 0
+if count < limit then
+	allowed = 1
+	if not peek then
+		redis.call('ZADD', KEYS[1], now, ARGV[4])
+		redis.call('PEXPIRE', KEYS[1], window)
+		count = count + 1
+	end
+end
+
+local reset = 0
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if newest[2] then
+	reset = tonumber(newest[2]) + window - now
+end
+
+local retry = 0
+if allowed == 0 then
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	retry = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, limit - count, reset, retry}
+`)
+
+// tokenBucketScript stores the tokens left and when they were last refilled
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local rate = capacity / window
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local peek = ARGV[3] == '1'
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	allowed = 1
+	if not peek then
+		tokens = tokens - 1
+		redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+		redis.call('PEXPIRE', KEYS[1], window)
+	end
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+return {allowed, math.floor(tokens), math.ceil((capacity - tokens) / rate), retry}
+`)
+
+// gcraScript stores the theoretical arrival time of the next request
+var gcraScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local emission = window / limit
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local tat = math.max(tonumber(redis.call('GET', KEYS[1])) or now, now)
+local new_tat = tat + emission
+local allow_at = new_tat - window
+
+if now < allow_at then
+	return {0, 0, math.ceil(tat - now), math.ceil(allow_at - now)}
+end
+
+if ARGV[3] == '1' then
+	return {1, math.min(limit, math.floor((now - allow_at) / emission) + 1), math.ceil(tat - now), 0}
+end
+
+redis.call('SET', KEYS[1], tostring(new_tat), 'PX', math.ceil(new_tat - now))
+return {1, math.floor((now - allow_at) / emission), math.ceil(new_tat - now), 0}
+`)
+
+// RedisStore shares limits between every instance of the API
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return s.run(ctx, key, limit, false)
+}
+
+func (s *RedisStore) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	return s.run(ctx, key, limit, true)
+}
+
+func (s *RedisStore) Reset(ctx context.Context, prefix string) error {
+	iter := s.client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+func (s *RedisStore) run(ctx context.Context, key string, limit Limit, peek bool) (Result, error) {
+	if limit.Algorithm == "" {
+		limit.Algorithm = SlidingWindow
+	}
+
+	peekArg := 0
+	if peek {
+		peekArg = 1
+	}
+
+	var script *redis.Script
+	args := []interface{}{limit.Requests, limit.Window.Milliseconds(), peekArg}
+
+	switch limit.Algorithm {
+	case TokenBucket:
+		script = tokenBucketScript
+	case GCRA:
+		script = gcraScript
+	default:
+		script = slidingWindowScript
+		// Members must be unique or requests in the same millisecond are counted once
+		args = append(args, fmt.Sprintf("%d-%x", time.Now().UnixNano(), rand.Uint64()))
+	}
+
+	// Algorithms store different types, so keep their keys apart
+	values, err := script.Run(ctx, s.client, []string{key + ":" + string(limit.Algorithm)}, args...).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 4 {
+		return Result{}, fmt.Errorf("rate limit script returned %d values", len(values))
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(max(values[1], 0)),
+		ResetAfter: time.Duration(values[2]) * time.Millisecond,
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
@@ -1,98 +1,238 @@
 package repository
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"time"
 
 	"social-media-app/internal/config"
+	"social-media-app/internal/database"
 	"social-media-app/internal/models"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
+// ErrCacheMiss is returned when an entry is not cached, or has been picked
+// to be refreshed before it expires
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheRepository caches viewer independent data. Timelines hold post IDs
+// only, so a post is cached once however many timelines it is on, and
+// per-viewer fields such as IsLiked are never stored.
+//
+// Setters take how long the value took to load. Entries that were slow to
+// load are more likely to be reported as a miss shortly before they expire,
+// so one caller refreshes them while the rest are still served from cache.
 type CacheRepository interface {
-	SetTimeline(userID uint, posts []models.PostResponse, expiry time.Duration) error
-	GetTimeline(userID uint) ([]models.PostResponse, error)
-	DeleteTimeline(userID uint) error
-	SetPostCache(postID uint, post models.PostResponse, expiry time.Duration) error
-	GetPostCache(postID uint) (*models.PostResponse, error)
-	DeletePostCache(postID uint) error
+	SetTimeline(ctx context.Context, userID uint, postIDs []uint, loadTime time.Duration) error
+	GetTimeline(ctx context.Context, userID uint) ([]uint, error)
+	DeleteTimeline(ctx context.Context, userID uint) error
+	SetPostCache(ctx context.Context, postID uint, post models.PostResponse, loadTime time.Duration) error
+	GetPostCache(ctx context.Context, postID uint) (*models.PostResponse, error)
+	// SetPostsCache and GetPostsCache read and write many posts in one round
+	// trip. Posts that aren't cached are missing from the returned map.
+	SetPostsCache(ctx context.Context, posts []models.PostResponse, loadTime time.Duration) error
+	GetPostsCache(ctx context.Context, postIDs []uint) (map[uint]models.PostResponse, error)
+	DeletePostCache(ctx context.Context, postID uint) error
+	Ping(ctx context.Context) error
 }
 
 type cacheRepository struct {
-	client *redis.Client
-	ctx    context.Context
+	client      *redis.Client
+	postTTL     time.Duration
+	timelineTTL time.Duration
+	beta        float64
 }
 
 func NewCacheRepository(cfg *config.Config) CacheRepository {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Host + ":" + cfg.Redis.Port,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
+	client := database.NewRedisClient(cfg, "cache")
 
 	return &cacheRepository{
-		client: client,
-		ctx:    context.Background(),
+		client:      client,
+		postTTL:     cfg.Cache.PostTTL,
+		timelineTTL: cfg.Cache.TimelineTTL,
+		beta:        cfg.Cache.EarlyRefreshBeta,
 	}
 }
 
-func (r *cacheRepository) SetTimeline(userID uint, posts []models.PostResponse, expiry time.Duration) error {
-	key := getTimelineKey(userID)
-	data, err := json.Marshal(posts)
+func (r *cacheRepository) SetTimeline(ctx context.Context, userID uint, postIDs []uint, loadTime time.Duration) error {
+	data, err := r.encode(postIDs, loadTime, r.timelineTTL)
 	if err != nil {
 		return err
 	}
-	return r.client.Set(r.ctx, key, data, expiry).Err()
+	return r.client.Set(ctx, getTimelineKey(userID), data, r.timelineTTL).Err()
 }
 
-func (r *cacheRepository) GetTimeline(userID uint) ([]models.PostResponse, error) {
-	key := getTimelineKey(userID)
-	data, err := r.client.Get(r.ctx, key).Result()
+func (r *cacheRepository) GetTimeline(ctx context.Context, userID uint) ([]uint, error) {
+	data, err := r.client.Get(ctx, getTimelineKey(userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var posts []models.PostResponse
-	err = json.Unmarshal([]byte(data), &posts)
-	return posts, err
+	var postIDs []uint
+	if err := r.decode(data, &postIDs); err != nil {
+		return nil, err
+	}
+	return postIDs, nil
 }
 
-func (r *cacheRepository) DeleteTimeline(userID uint) error {
+func (r *cacheRepository) DeleteTimeline(ctx context.Context, userID uint) error {
 	key := getTimelineKey(userID)
-	return r.client.Del(r.ctx, key).Err()
+	return r.client.Del(ctx, key).Err()
 }
 
-func (r *cacheRepository) SetPostCache(postID uint, post models.PostResponse, expiry time.Duration) error {
-	key := getPostKey(postID)
-	data, err := json.Marshal(post)
+func (r *cacheRepository) SetPostCache(ctx context.Context, postID uint, post models.PostResponse, loadTime time.Duration) error {
+	data, err := r.encode(post, loadTime, r.postTTL)
 	if err != nil {
 		return err
 	}
-	return r.client.Set(r.ctx, key, data, expiry).Err()
+	return r.client.Set(ctx, getPostKey(postID), data, r.postTTL).Err()
 }
 
-func (r *cacheRepository) GetPostCache(postID uint) (*models.PostResponse, error) {
-	key := getPostKey(postID)
-	data, err := r.client.Get(r.ctx, key).Result()
+func (r *cacheRepository) GetPostCache(ctx context.Context, postID uint) (*models.PostResponse, error) {
+	data, err := r.client.Get(ctx, getPostKey(postID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	var post models.PostResponse
-	err = json.Unmarshal([]byte(data), &post)
-	return &post, err
+	if err := r.decode(data, &post); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (r *cacheRepository) SetPostsCache(ctx context.Context, posts []models.PostResponse, loadTime time.Duration) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for _, post := range posts {
+		data, err := r.encode(post, loadTime, r.postTTL)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, getPostKey(post.ID), data, r.postTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *cacheRepository) GetPostsCache(ctx context.Context, postIDs []uint) (map[uint]models.PostResponse, error) {
+	posts := make(map[uint]models.PostResponse, len(postIDs))
+	if len(postIDs) == 0 {
+		return posts, nil
+	}
+
+	keys := make([]string, len(postIDs))
+	for i, postID := range postIDs {
+		keys[i] = getPostKey(postID)
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return posts, err
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // not cached
+		}
+		var post models.PostResponse
+		if err := r.decode([]byte(data), &post); err != nil {
+			continue // unreadable or due for refresh
+		}
+		posts[post.ID] = post
+	}
+	return posts, nil
 }
 
-func (r *cacheRepository) DeletePostCache(postID uint) error {
+func (r *cacheRepository) DeletePostCache(ctx context.Context, postID uint) error {
 	key := getPostKey(postID)
-	return r.client.Del(r.ctx, key).Err()
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *cacheRepository) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// cacheEntry is the stored form of every cached value, carrying what is
+// needed to decide when to refresh it early
+type cacheEntry struct {
+	Value    msgpack.RawMessage `msgpack:"v"`
+	LoadTime int64              `msgpack:"l"` // milliseconds
+	Expiry   int64              `msgpack:"e"` // Unix milliseconds
+}
+
+// encode serializes value as MessagePack, keyed by the fields' JSON names so
+// entries survive fields being added or reordered
+func (r *cacheRepository) encode(value interface{}, loadTime, ttl time.Duration) ([]byte, error) {
+	raw, err := marshalMsgpack(value)
+	if err != nil {
+		return nil, err
+	}
+	return marshalMsgpack(cacheEntry{
+		Value:    raw,
+		LoadTime: loadTime.Milliseconds(),
+		Expiry:   time.Now().Add(ttl).UnixMilli(),
+	})
+}
+
+// decode reads an entry written by encode into value, returning ErrCacheMiss
+// if the entry should be refreshed early
+func (r *cacheRepository) decode(data []byte, value interface{}) error {
+	var entry cacheEntry
+	if err := msgpack.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+
+	if r.refreshEarly(entry) {
+		return ErrCacheMiss
+	}
+
+	decoder := msgpack.NewDecoder(bytes.NewReader(entry.Value))
+	decoder.SetCustomStructTag("json")
+	return decoder.Decode(value)
+}
+
+// refreshEarly picks entries for refresh with a probability that rises
+// sharply as expiry nears, earlier for values that were slow to load
+// (the XFetch algorithm). Concurrent readers rarely pick the same entry, so
+// it is refreshed by one of them rather than all at once when it expires.
+func (r *cacheRepository) refreshEarly(entry cacheEntry) bool {
+	if r.beta <= 0 || entry.LoadTime <= 0 {
+		return false
+	}
+	gap := -float64(entry.LoadTime) * r.beta * math.Log(1-rand.Float64())
+	return float64(time.Now().UnixMilli())+gap >= float64(entry.Expiry)
+}
+
+func marshalMsgpack(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	encoder.SetOmitEmpty(true)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func getTimelineKey(userID uint) string {
-	return fmt.Sprintf("timeline:%d", userID)
+	return fmt.Sprintf("timeline_ids:%d", userID)
 }
 
 func getPostKey(postID uint) string {
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+
+	"social-media-app/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type externalIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewExternalIdentityRepository(db *gorm.DB) ExternalIdentityRepository {
+	return &externalIdentityRepository{db: db}
+}
+
+func (r *externalIdentityRepository) Create(ctx context.Context, identity *models.ExternalIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *externalIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *externalIdentityRepository) GetByUserID(ctx context.Context, userID uint) ([]models.ExternalIdentity, error) {
+	var identities []models.ExternalIdentity
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&identities).Error
+	return identities, err
+}
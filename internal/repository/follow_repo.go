@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"social-media-app/internal/models"
 
 	"gorm.io/gorm"
@@ -14,40 +16,89 @@ func NewFollowRepository(db *gorm.DB) FollowRepository {
 	return &followRepository{db: db}
 }
 
-func (r *followRepository) Create(follow *models.Follow) error {
+// Create stores a follow and increments both users' follow counts
+func (r *followRepository) Create(ctx context.Context, follow *models.Follow) error {
 	// Use a transaction to ensure atomicity
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		return tx.Create(follow).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(follow).Error; err != nil {
+			return err
+		}
+		return adjustFollowCounts(tx, follow.FollowerID, follow.FollowingID, "+ 1")
 	})
 }
 
-func (r *followRepository) Delete(followerID, followingID uint) error {
+func (r *followRepository) Delete(ctx context.Context, followerID, followingID uint) error {
 	// Use transaction for consistency
-	return r.db.Transaction(func(tx *gorm.DB) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Use Unscoped to permanently delete the record instead of soft delete
 		result := tx.Unscoped().Where("follower_id = ? AND following_id = ?", followerID, followingID).Delete(&models.Follow{})
-		if result.Error != nil {
+		// Even if no rows were affected, don't return an error (idempotent operation)
+		if result.Error != nil || result.RowsAffected == 0 {
 			return result.Error
 		}
-		// Even if no rows were affected, don't return an error (idempotent operation)
-		return nil
+		return adjustFollowCounts(tx, followerID, followingID, "- 1")
 	})
 }
 
-func (r *followRepository) Exists(followerID, followingID uint) (bool, error) {
+// adjustFollowCounts applies delta ("+ 1" or "- 1") to the follower's
+// following count and the followed user's follower count
+func adjustFollowCounts(tx *gorm.DB, followerID, followingID uint, delta string) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", followerID).
+		UpdateColumn("following_count", gorm.Expr("GREATEST(following_count "+delta+", 0)")).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("id = ?", followingID).
+		UpdateColumn("follower_count", gorm.Expr("GREATEST(follower_count "+delta+", 0)")).Error
+}
+
+func (r *followRepository) Exists(ctx context.Context, followerID, followingID uint) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.Follow{}).Where("follower_id = ? AND following_id = ?", followerID, followingID).Count(&count).Error
+	err := r.db.WithContext(ctx).Model(&models.Follow{}).Where("follower_id = ? AND following_id = ?", followerID, followingID).Count(&count).Error
 	return count > 0, err
 }
 
-func (r *followRepository) GetFollowers(userID uint) ([]models.Follow, error) {
+// GetFollowers returns a page of the user's followers, most recent first
+func (r *followRepository) GetFollowers(ctx context.Context, userID uint, limit, offset int) ([]models.Follow, error) {
 	var follows []models.Follow
-	err := r.db.Preload("Follower").Where("following_id = ?", userID).Find(&follows).Error
+	err := r.db.WithContext(ctx).Clauses(readReplica).Preload("Follower").Where("following_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).
+		Find(&follows).Error
 	return follows, err
 }
 
-func (r *followRepository) GetFollowing(userID uint) ([]models.Follow, error) {
+// GetFollowing returns a page of the users the user follows, most recent first
+func (r *followRepository) GetFollowing(ctx context.Context, userID uint, limit, offset int) ([]models.Follow, error) {
 	var follows []models.Follow
-	err := r.db.Preload("Following").Where("follower_id = ?", userID).Find(&follows).Error
+	err := r.db.WithContext(ctx).Clauses(readReplica).Preload("Following").Where("follower_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).
+		Find(&follows).Error
 	return follows, err
 }
+
+// FollowedIDs returns the IDs among userIDs that the follower follows, in a single query
+func (r *followRepository) FollowedIDs(ctx context.Context, followerID uint, userIDs []uint) ([]uint, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	var followed []uint
+	err := r.db.WithContext(ctx).Model(&models.Follow{}).
+		Where("follower_id = ? AND following_id IN ?", followerID, userIDs).
+		Pluck("following_id", &followed).Error
+	return followed, err
+}
+
+// FollowerIDs returns the IDs among userIDs that follow the user, in a single query
+func (r *followRepository) FollowerIDs(ctx context.Context, followingID uint, userIDs []uint) ([]uint, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	var followers []uint
+	err := r.db.WithContext(ctx).Model(&models.Follow{}).
+		Where("following_id = ? AND follower_id IN ?", followingID, userIDs).
+		Pluck("follower_id", &followers).Error
+	return followers, err
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/database"
+	"social-media-app/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyRepository stores responses so retried requests can be replayed
+type IdempotencyRepository interface {
+	// Reserve claims the key for a request in progress. If the key is already
+	// taken the existing record is returned and nothing is written.
+	Reserve(ctx context.Context, key string, record models.IdempotencyRecord, expiry time.Duration) (*models.IdempotencyRecord, error)
+	Save(ctx context.Context, key string, record models.IdempotencyRecord, expiry time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type idempotencyRepository struct {
+	client *redis.Client
+}
+
+func NewIdempotencyRepository(cfg *config.Config) IdempotencyRepository {
+	client := database.NewRedisClient(cfg, "idempotency")
+
+	return &idempotencyRepository{
+		client: client,
+	}
+}
+
+func (r *idempotencyRepository) Reserve(ctx context.Context, key string, record models.IdempotencyRecord, expiry time.Duration) (*models.IdempotencyRecord, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	// The existing record can expire between SETNX and GET, so try again once
+	for attempt := 0; attempt < 2; attempt++ {
+		reserved, err := r.client.SetNX(ctx, getIdempotencyKey(key), payload, expiry).Result()
+		if err != nil {
+			return nil, err
+		}
+		if reserved {
+			return nil, nil
+		}
+
+		existing, err := r.client.Get(ctx, getIdempotencyKey(key)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var stored models.IdempotencyRecord
+		if err := json.Unmarshal(existing, &stored); err != nil {
+			return nil, err
+		}
+		return &stored, nil
+	}
+
+	return nil, errors.New("failed to reserve idempotency key")
+}
+
+func (r *idempotencyRepository) Save(ctx context.Context, key string, record models.IdempotencyRecord, expiry time.Duration) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, getIdempotencyKey(key), payload, expiry).Err()
+}
+
+func (r *idempotencyRepository) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, getIdempotencyKey(key)).Err()
+}
+
+func getIdempotencyKey(key string) string {
+	return fmt.Sprintf("idempotency:%s", key)
+}
@@ -1,47 +1,93 @@
 package repository
 
-import "social-media-app/internal/models"
+import (
+	"context"
+	"time"
+
+	"social-media-app/internal/models"
+)
 
 // UserRepository defines user database operations
 type UserRepository interface {
-	Create(user *models.User) error
-	GetByID(id uint) (*models.User, error)
-	GetByEmail(email string) (*models.User, error)
-	GetByUsername(username string) (*models.User, error)
-	Update(user *models.User) error
-	Delete(id uint) error
-	EmailExists(email string) (bool, error)
-	UsernameExists(username string) (bool, error)
-	SearchUsers(query string, limit, offset int) ([]models.User, error)
-	GetFollowers(userID uint) ([]models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	UseTOTPStep(ctx context.Context, id uint, step int64) (bool, error)
+	Delete(ctx context.Context, id uint) error
+	EmailExists(ctx context.Context, email string) (bool, error)
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]models.User, error)
+	GetFollowerIDs(ctx context.Context, userID, afterID uint, limit int) ([]uint, error)
+	List(ctx context.Context, limit, offset int) ([]models.User, error)
 }
 
 // PostRepository defines post database operations
 type PostRepository interface {
-	Create(post *models.Post) error
-	GetByID(id uint) (*models.Post, error)
-	GetByUserID(userID uint, limit, offset int) ([]models.Post, error)
-	GetAll(limit, offset int) ([]models.Post, error)
-	Update(post *models.Post) error
-	Delete(id uint) error
-	GetTimeline(userID uint, limit, offset int) ([]models.Post, error)
+	Create(ctx context.Context, post *models.Post) error
+	GetByID(ctx context.Context, id uint) (*models.Post, error)
+	GetByUserID(ctx context.Context, userID uint, limit, offset int) ([]models.Post, error)
+	GetAll(ctx context.Context, limit, offset int) ([]models.Post, error)
+	Update(ctx context.Context, post *models.Post) error
+	Delete(ctx context.Context, id uint) error
+	GetTimeline(ctx context.Context, userID uint, limit, offset int) ([]models.Post, error)
+	GetTimelineIDs(ctx context.Context, userID uint, limit int) ([]uint, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]models.Post, error)
+	SetHidden(ctx context.Context, id uint, hidden bool) error
+	CountRecentDuplicates(ctx context.Context, userID, excludePostID uint, content string, since time.Time) (int64, error)
 }
 
 // LikeRepository defines like database operations
 type LikeRepository interface {
-	Create(like *models.Like) error
-	Delete(userID, postID uint) error
-	Exists(userID, postID uint) (bool, error)
-	GetByPostID(postID uint) ([]models.Like, error)
-	GetLikeCount(postID uint) (int64, error)
-	GetLikedUserIDs(postID uint) ([]uint, error)
+	Create(ctx context.Context, like *models.Like) error
+	Delete(ctx context.Context, userID, postID uint) error
+	Exists(ctx context.Context, userID, postID uint) (bool, error)
+	GetByPostID(ctx context.Context, postID uint) ([]models.Like, error)
+	GetLikeCount(ctx context.Context, postID uint) (int64, error)
+	GetLikedUserIDs(ctx context.Context, postID uint) ([]uint, error)
+	LikedPostIDs(ctx context.Context, userID uint, postIDs []uint) ([]uint, error)
 }
 
 // FollowRepository defines follow database operations
 type FollowRepository interface {
-	Create(follow *models.Follow) error
-	Delete(followerID, followingID uint) error
-	Exists(followerID, followingID uint) (bool, error)
-	GetFollowers(userID uint) ([]models.Follow, error)
-	GetFollowing(userID uint) ([]models.Follow, error)
+	Create(ctx context.Context, follow *models.Follow) error
+	Delete(ctx context.Context, followerID, followingID uint) error
+	Exists(ctx context.Context, followerID, followingID uint) (bool, error)
+	GetFollowers(ctx context.Context, userID uint, limit, offset int) ([]models.Follow, error)
+	GetFollowing(ctx context.Context, userID uint, limit, offset int) ([]models.Follow, error)
+	FollowedIDs(ctx context.Context, followerID uint, userIDs []uint) ([]uint, error)
+	FollowerIDs(ctx context.Context, followingID uint, userIDs []uint) ([]uint, error)
+}
+
+// RecoveryCodeRepository defines two-factor recovery code database operations
+type RecoveryCodeRepository interface {
+	ReplaceForUser(ctx context.Context, userID uint, codeHashes []string) error
+	GetUnused(ctx context.Context, userID uint) ([]models.RecoveryCode, error)
+	MarkUsed(ctx context.Context, id uint) (bool, error)
+	DeleteForUser(ctx context.Context, userID uint) error
+}
+
+// ExternalIdentityRepository defines linked OAuth identity database operations
+type ExternalIdentityRepository interface {
+	Create(ctx context.Context, identity *models.ExternalIdentity) error
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*models.ExternalIdentity, error)
+	GetByUserID(ctx context.Context, userID uint) ([]models.ExternalIdentity, error)
+}
+
+// AuditLogRepository defines audit log database operations
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+	List(ctx context.Context, filter models.AuditLogFilter, limit, offset int) ([]models.AuditLog, error)
+}
+
+// ReportRepository defines content report database operations
+type ReportRepository interface {
+	Create(ctx context.Context, report *models.Report) error
+	GetByID(ctx context.Context, id uint) (*models.Report, error)
+	Update(ctx context.Context, report *models.Report) error
+	ExistsOpen(ctx context.Context, reporterID uint, targetType models.ReportTargetType, targetID uint) (bool, error)
+	CountOpenReporters(ctx context.Context, targetType models.ReportTargetType, targetID uint) (int64, error)
+	List(ctx context.Context, filter models.ReportFilter, limit, offset int) ([]models.Report, error)
+	CloseOpenForTarget(ctx context.Context, targetType models.ReportTargetType, targetID uint, status models.ReportStatus, resolvedByID uint, note string) error
 }
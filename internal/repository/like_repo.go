@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"social-media-app/internal/models"
 
 	"gorm.io/gorm"
@@ -14,38 +16,68 @@ func NewLikeRepository(db *gorm.DB) LikeRepository {
 	return &likeRepository{db: db}
 }
 
-func (r *likeRepository) Create(like *models.Like) error {
-	return r.db.Create(like).Error
+// Create stores a like and increments the post's like count
+func (r *likeRepository) Create(ctx context.Context, like *models.Like) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(like).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Post{}).Where("id = ?", like.PostID).
+			UpdateColumn("like_count", gorm.Expr("like_count + 1")).Error
+	})
 }
 
-// Delete removes a like based on who liked it (liked_by) and post_id
-func (r *likeRepository) Delete(likedBy, postID uint) error {
-	return r.db.Where("liked_by = ? AND post_id = ?", likedBy, postID).Delete(&models.Like{}).Error
+// Delete removes a like based on who liked it (liked_by) and post_id, and
+// decrements the post's like count
+func (r *likeRepository) Delete(ctx context.Context, likedBy, postID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Permanently delete so the post can be liked again without hitting
+		// the unique constraint
+		result := tx.Unscoped().Where("liked_by = ? AND post_id = ?", likedBy, postID).Delete(&models.Like{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Post{}).Where("id = ?", postID).
+			UpdateColumn("like_count", gorm.Expr("GREATEST(like_count - 1, 0)")).Error
+	})
 }
 
 // Exists checks if a user has already liked a post
-func (r *likeRepository) Exists(likedBy, postID uint) (bool, error) {
+func (r *likeRepository) Exists(ctx context.Context, likedBy, postID uint) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.Like{}).Where("liked_by = ? AND post_id = ?", likedBy, postID).Count(&count).Error
+	err := r.db.WithContext(ctx).Model(&models.Like{}).Where("liked_by = ? AND post_id = ?", likedBy, postID).Count(&count).Error
 	return count > 0, err
 }
 
-func (r *likeRepository) GetByPostID(postID uint) ([]models.Like, error) {
+func (r *likeRepository) GetByPostID(ctx context.Context, postID uint) ([]models.Like, error) {
 	var likes []models.Like
-	err := r.db.Preload("User").Where("post_id = ?", postID).Find(&likes).Error
+	err := r.db.WithContext(ctx).Preload("User").Where("post_id = ?", postID).Find(&likes).Error
 	return likes, err
 }
 
 // GetLikeCount returns the total number of likes for a post
-func (r *likeRepository) GetLikeCount(postID uint) (int64, error) {
+func (r *likeRepository) GetLikeCount(ctx context.Context, postID uint) (int64, error) {
 	var count int64
-	err := r.db.Model(&models.Like{}).Where("post_id = ?", postID).Count(&count).Error
+	err := r.db.WithContext(ctx).Model(&models.Like{}).Where("post_id = ?", postID).Count(&count).Error
 	return count, err
 }
 
 // GetLikedUserIDs returns all user IDs who liked a specific post
-func (r *likeRepository) GetLikedUserIDs(postID uint) ([]uint, error) {
+func (r *likeRepository) GetLikedUserIDs(ctx context.Context, postID uint) ([]uint, error) {
 	var userIDs []uint
-	err := r.db.Model(&models.Like{}).Where("post_id = ?", postID).Pluck("liked_by", &userIDs).Error
+	err := r.db.WithContext(ctx).Model(&models.Like{}).Where("post_id = ?", postID).Pluck("liked_by", &userIDs).Error
 	return userIDs, err
 }
+
+// LikedPostIDs returns the IDs among postIDs that the user has liked, in a single query
+func (r *likeRepository) LikedPostIDs(ctx context.Context, likedBy uint, postIDs []uint) ([]uint, error) {
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+
+	var liked []uint
+	err := r.db.WithContext(ctx).Model(&models.Like{}).
+		Where("liked_by = ? AND post_id IN ?", likedBy, postIDs).
+		Pluck("post_id", &liked).Error
+	return liked, err
+}
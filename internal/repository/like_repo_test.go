@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"social-media-app/internal/models"
+)
+
+// Posts per page when marking which posts the viewer has liked
+const benchmarkPageSize = 20
+
+// BenchmarkLikedPosts compares marking a page of posts as liked with one
+// LikedPostIDs query against one Exists query per post. It needs a Postgres
+// database, e.g.
+//
+//	TEST_DATABASE_DSN="host=localhost user=admin password=... dbname=social_media_test sslmode=disable" \
+//		go test ./internal/repository -run '^$' -bench LikedPosts
+func BenchmarkLikedPosts(b *testing.B) {
+	db := openTestDB(b)
+	repo := NewLikeRepository(db)
+	viewerID, postIDs := seedLikedPage(b, db)
+	ctx := context.Background()
+
+	b.Run("LikedPostIDs", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			liked, err := repo.LikedPostIDs(ctx, viewerID, postIDs)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(liked) != len(postIDs)/2 {
+				b.Fatalf("got %d liked posts, want %d", len(liked), len(postIDs)/2)
+			}
+		}
+	})
+
+	b.Run("ExistsPerPost", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			liked := 0
+			for _, postID := range postIDs {
+				exists, err := repo.Exists(ctx, viewerID, postID)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if exists {
+					liked++
+				}
+			}
+			if liked != len(postIDs)/2 {
+				b.Fatalf("got %d liked posts, want %d", liked, len(postIDs)/2)
+			}
+		}
+	})
+}
+
+// openTestDB connects to the database named by TEST_DATABASE_DSN, skipping
+// when it isn't set
+func openTestDB(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		tb.Fatalf("connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Post{}, &models.Like{}); err != nil {
+		tb.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// seedLikedPage creates a viewer and a page of posts, half of them liked by
+// the viewer, and removes them when the benchmark ends
+func seedLikedPage(tb testing.TB, db *gorm.DB) (uint, []uint) {
+	tb.Helper()
+
+	suffix := time.Now().UnixNano()
+	viewer := &models.User{
+		Username: fmt.Sprintf("bench_%d", suffix),
+		Email:    fmt.Sprintf("bench_%d@example.com", suffix),
+		Password: "x",
+	}
+	if err := db.Create(viewer).Error; err != nil {
+		tb.Fatalf("create viewer: %v", err)
+	}
+
+	posts := make([]models.Post, benchmarkPageSize)
+	for i := range posts {
+		posts[i] = models.Post{UserID: viewer.ID, Content: fmt.Sprintf("post %d", i)}
+	}
+	if err := db.Create(&posts).Error; err != nil {
+		tb.Fatalf("create posts: %v", err)
+	}
+
+	postIDs := make([]uint, len(posts))
+	var likes []models.Like
+	for i, post := range posts {
+		postIDs[i] = post.ID
+		if i%2 == 0 {
+			likes = append(likes, models.Like{UserID: viewer.ID, LikedBy: viewer.ID, PostID: post.ID})
+		}
+	}
+	if err := db.Create(&likes).Error; err != nil {
+		tb.Fatalf("create likes: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		db.Unscoped().Where("post_id IN ?", postIDs).Delete(&models.Like{})
+		db.Unscoped().Where("id IN ?", postIDs).Delete(&models.Post{})
+		db.Unscoped().Delete(viewer)
+	})
+
+	return viewer.ID, postIDs
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LockoutPolicy controls when repeated login attempts lock an account
+type LockoutPolicy struct {
+	MaxAttempts int           // attempts allowed before the account is locked
+	Window      time.Duration // how long attempts are remembered
+	Base        time.Duration // first lockout, doubled for each further attempt
+	Max         time.Duration // longest lockout
+}
+
+// LoginAttempt is the outcome of recording a login attempt
+type LoginAttempt struct {
+	Attempts int64         // attempts counted in the current window
+	Lockout  time.Duration // remaining lockout, zero if the attempt may go ahead
+	Locked   bool          // whether this attempt started the lockout
+}
+
+// LoginAttemptRepository tracks login attempts and lockouts per account
+type LoginAttemptRepository interface {
+	// RecordAttempt counts an attempt and, in the same atomic step, decides
+	// whether it is allowed
+	RecordAttempt(ctx context.Context, identifier string, policy LockoutPolicy) (LoginAttempt, error)
+	Reset(ctx context.Context, identifier string) error
+}
+
+// attemptScript returns {attempts, lockout ms, 1 if this attempt locked the
+// account}. Attempts made while locked are refused without being counted.
+var attemptScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+	return {tonumber(redis.call('GET', KEYS[1]) or 0), ttl, 0}
+end
+
+local max_attempts = tonumber(ARGV[1])
+local base = tonumber(ARGV[3])
+local max_lockout = tonumber(ARGV[4])
+
+local attempts = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if attempts <= max_attempts then
+	return {attempts, 0, 0}
+end
+
+local lockout = base
+for i = max_attempts + 2, attempts do
+	if lockout >= max_lockout then
+		break
+	end
+	lockout = lockout * 2
+end
+if lockout > max_lockout then
+	lockout = max_lockout
+end
+
+redis.call('SET', KEYS[2], 1, 'PX', lockout)
+return {attempts, lockout, 1}
+`)
+
+type loginAttemptRepository struct {
+	client *redis.Client
+}
+
+func NewLoginAttemptRepository(cfg *config.Config) LoginAttemptRepository {
+	client := database.NewRedisClient(cfg, "login_attempts")
+
+	return &loginAttemptRepository{
+		client: client,
+	}
+}
+
+// RecordAttempt counts the attempt before the credentials are checked, so
+// concurrent guesses can't all get in ahead of the lockout. The counter is
+// kept through the longest lockout so backoff keeps growing.
+func (r *loginAttemptRepository) RecordAttempt(ctx context.Context, identifier string, policy LockoutPolicy) (LoginAttempt, error) {
+	keys := []string{getLoginAttemptsKey(identifier), getLoginLockoutKey(identifier)}
+	values, err := attemptScript.Run(ctx, r.client, keys,
+		policy.MaxAttempts,
+		(policy.Window + policy.Max).Milliseconds(),
+		policy.Base.Milliseconds(),
+		policy.Max.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return LoginAttempt{}, err
+	}
+
+	return LoginAttempt{
+		Attempts: values[0],
+		Lockout:  time.Duration(values[1]) * time.Millisecond,
+		Locked:   values[2] == 1,
+	}, nil
+}
+
+// Reset clears both the attempt counter and any active lockout
+func (r *loginAttemptRepository) Reset(ctx context.Context, identifier string) error {
+	return r.client.Del(ctx, getLoginAttemptsKey(identifier), getLoginLockoutKey(identifier)).Err()
+}
+
+func getLoginAttemptsKey(identifier string) string {
+	return fmt.Sprintf("login_attempts:%s", identifier)
+}
+
+func getLoginLockoutKey(identifier string) string {
+	return fmt.Sprintf("login_lockout:%s", identifier)
+}
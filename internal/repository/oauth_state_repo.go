@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/database"
+	"social-media-app/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// OAuthStateRepository keeps pending authorization requests between redirect and callback
+type OAuthStateRepository interface {
+	Save(ctx context.Context, state string, data models.OAuthState, expiry time.Duration) error
+	// Consume returns the stored data and deletes it so a state can only be used once
+	Consume(ctx context.Context, state string) (*models.OAuthState, error)
+}
+
+type oauthStateRepository struct {
+	client *redis.Client
+}
+
+func NewOAuthStateRepository(cfg *config.Config) OAuthStateRepository {
+	client := database.NewRedisClient(cfg, "oauth_state")
+
+	return &oauthStateRepository{
+		client: client,
+	}
+}
+
+func (r *oauthStateRepository) Save(ctx context.Context, state string, data models.OAuthState, expiry time.Duration) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, getOAuthStateKey(state), payload, expiry).Err()
+}
+
+func (r *oauthStateRepository) Consume(ctx context.Context, state string) (*models.OAuthState, error) {
+	payload, err := r.client.GetDel(ctx, getOAuthStateKey(state)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var data models.OAuthState
+	err = json.Unmarshal([]byte(payload), &data)
+	return &data, err
+}
+
+func getOAuthStateKey(state string) string {
+	return fmt.Sprintf("oauth_state:%s", state)
+}
@@ -1,6 +1,10 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"social-media-app/internal/models"
 
 	"gorm.io/gorm"
@@ -14,56 +18,119 @@ func NewPostRepository(db *gorm.DB) PostRepository {
 	return &postRepository{db: db}
 }
 
-func (r *postRepository) Create(post *models.Post) error {
-	return r.db.Create(post).Error
+// Create stores a post and increments its author's post count
+func (r *postRepository) Create(ctx context.Context, post *models.Post) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(post).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", post.UserID).
+			UpdateColumn("post_count", gorm.Expr("post_count + 1")).Error
+	})
 }
 
-func (r *postRepository) GetByID(id uint) (*models.Post, error) {
+func (r *postRepository) GetByID(ctx context.Context, id uint) (*models.Post, error) {
 	var post models.Post
-	err := r.db.Preload("User").First(&post, id).Error
+	err := r.db.WithContext(ctx).Preload("User").First(&post, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &post, nil
 }
 
-func (r *postRepository) GetByUserID(userID uint, limit, offset int) ([]models.Post, error) {
+func (r *postRepository) GetByUserID(ctx context.Context, userID uint, limit, offset int) ([]models.Post, error) {
 	var posts []models.Post
-	err := r.db.Preload("User").Where("user_id = ?", userID).
+	err := r.db.WithContext(ctx).Clauses(readReplica).Preload("User").Where("user_id = ? AND is_hidden = ?", userID, false).
 		Order("created_at DESC").
 		Limit(limit).Offset(offset).
 		Find(&posts).Error
 	return posts, err
 }
 
-func (r *postRepository) GetAll(limit, offset int) ([]models.Post, error) {
+func (r *postRepository) GetAll(ctx context.Context, limit, offset int) ([]models.Post, error) {
 	var posts []models.Post
-	err := r.db.Preload("User").
+	err := r.db.WithContext(ctx).Clauses(readReplica).Preload("User").
+		Where("is_hidden = ?", false).
 		Order("created_at DESC").
 		Limit(limit).Offset(offset).
 		Find(&posts).Error
 	return posts, err
 }
 
-func (r *postRepository) Update(post *models.Post) error {
+func (r *postRepository) Update(ctx context.Context, post *models.Post) error {
 	// Use Updates instead of Save to only update specific fields
 	// This prevents updating timestamps automatically
-	return r.db.Model(post).Updates(map[string]interface{}{
+	return r.db.WithContext(ctx).Model(post).Updates(map[string]interface{}{
 		"content":   post.Content,
 		"image_url": post.ImageURL,
 	}).Error
 }
 
-func (r *postRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Post{}, id).Error
+// Delete soft deletes a post and decrements its author's post count
+func (r *postRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var post models.Post
+		err := tx.Select("id", "user_id").First(&post, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // already deleted
+		}
+		if err != nil {
+			return err
+		}
+		result := tx.Delete(&post)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.User{}).Where("id = ?", post.UserID).
+			UpdateColumn("post_count", gorm.Expr("GREATEST(post_count - 1, 0)")).Error
+	})
 }
 
-func (r *postRepository) GetTimeline(userID uint, limit, offset int) ([]models.Post, error) {
+func (r *postRepository) GetTimeline(ctx context.Context, userID uint, limit, offset int) ([]models.Post, error) {
 	var posts []models.Post
-	err := r.db.Preload("User").
+	err := r.db.WithContext(ctx).Clauses(readReplica).Preload("User").
 		Where("user_id IN (SELECT following_id FROM follows WHERE follower_id = ?) OR user_id = ?", userID, userID).
+		Where("is_hidden = ?", false).
 		Order("created_at DESC").
 		Limit(limit).Offset(offset).
 		Find(&posts).Error
 	return posts, err
 }
+
+// GetTimelineIDs returns the IDs of the newest posts on the user's timeline
+func (r *postRepository) GetTimelineIDs(ctx context.Context, userID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Clauses(readReplica).Model(&models.Post{}).
+		Where("user_id IN (SELECT following_id FROM follows WHERE follower_id = ?) OR user_id = ?", userID, userID).
+		Where("is_hidden = ?", false).
+		Order("created_at DESC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetByIDs loads posts with their authors, in no particular order. Missing
+// IDs are skipped.
+func (r *postRepository) GetByIDs(ctx context.Context, ids []uint) ([]models.Post, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var posts []models.Post
+	err := r.db.WithContext(ctx).Preload("User").Where("id IN ?", ids).Find(&posts).Error
+	return posts, err
+}
+
+// SetHidden hides a post from listings pending moderation, or restores it
+func (r *postRepository) SetHidden(ctx context.Context, id uint, hidden bool) error {
+	return r.db.WithContext(ctx).Model(&models.Post{}).Where("id = ?", id).Update("is_hidden", hidden).Error
+}
+
+// CountRecentDuplicates counts the user's posts since the given time with identical content
+func (r *postRepository) CountRecentDuplicates(ctx context.Context, userID, excludePostID uint, content string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Post{}).
+		Where("user_id = ? AND id <> ? AND content = ? AND created_at >= ?", userID, excludePostID, content, since).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"gorm.io/plugin/dbresolver"
+
+	"social-media-app/internal/database"
+)
+
+// readReplica routes a query to a read replica when replicas are configured.
+// Only use it for listings that can tolerate replication lag, never to read
+// back something the request has just written.
+var readReplica = dbresolver.Use(database.ReplicaResolver)
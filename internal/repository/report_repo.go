@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"social-media-app/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+func (r *reportRepository) Create(ctx context.Context, report *models.Report) error {
+	return r.db.WithContext(ctx).Create(report).Error
+}
+
+func (r *reportRepository) GetByID(ctx context.Context, id uint) (*models.Report, error) {
+	var report models.Report
+	err := r.db.WithContext(ctx).First(&report, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) Update(ctx context.Context, report *models.Report) error {
+	return r.db.WithContext(ctx).Save(report).Error
+}
+
+// ExistsOpen checks if the reporter already has an open report against the target
+func (r *reportRepository) ExistsOpen(ctx context.Context, reporterID uint, targetType models.ReportTargetType, targetID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Report{}).
+		Where("reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?",
+			reporterID, targetType, targetID, models.ReportStatusOpen).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CountOpenReporters returns how many distinct users have open reports against the target
+func (r *reportRepository) CountOpenReporters(ctx context.Context, targetType models.ReportTargetType, targetID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Report{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReportStatusOpen).
+		Distinct("reporter_id").
+		Count(&count).Error
+	return count, err
+}
+
+// List returns reports matching the filter, oldest first so the queue is worked in order
+func (r *reportRepository) List(ctx context.Context, filter models.ReportFilter, limit, offset int) ([]models.Report, error) {
+	query := r.db.WithContext(ctx).Model(&models.Report{})
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.AssigneeID != 0 {
+		query = query.Where("assignee_id = ?", filter.AssigneeID)
+	}
+	if filter.Unassigned {
+		query = query.Where("assignee_id IS NULL")
+	}
+
+	var reports []models.Report
+	err := query.Order("created_at ASC").
+		Limit(limit).Offset(offset).
+		Find(&reports).Error
+	return reports, err
+}
+
+// CloseOpenForTarget resolves or dismisses every open report against the target
+func (r *reportRepository) CloseOpenForTarget(ctx context.Context, targetType models.ReportTargetType, targetID uint, status models.ReportStatus, resolvedByID uint, note string) error {
+	return r.db.WithContext(ctx).Model(&models.Report{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReportStatusOpen).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"resolved_by_id":  resolvedByID,
+			"resolution_note": note,
+			"resolved_at":     time.Now(),
+		}).Error
+}
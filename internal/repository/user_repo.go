@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"social-media-app/internal/models"
 
 	"gorm.io/gorm"
@@ -16,14 +18,14 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 }
 
 // Create creates a new user
-func (r *userRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
 }
 
 // GetByID gets user by ID
-func (r *userRepository) GetByID(id uint) (*models.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
 	var user models.User
-	err := r.db.First(&user, id).Error
+	err := r.db.WithContext(ctx).First(&user, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -31,9 +33,9 @@ func (r *userRepository) GetByID(id uint) (*models.User, error) {
 }
 
 // GetByEmail gets user by email
-func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
@@ -41,9 +43,9 @@ func (r *userRepository) GetByEmail(email string) (*models.User, error) {
 }
 
 // GetByUsername gets user by username
-func (r *userRepository) GetByUsername(username string) (*models.User, error) {
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("username = ?", username).First(&user).Error
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
@@ -51,44 +53,68 @@ func (r *userRepository) GetByUsername(username string) (*models.User, error) {
 }
 
 // Update updates user
-func (r *userRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	// The counters are kept up to date by concurrent follows and posts, and
+	// the last TOTP step by UseTOTPStep, so writing back the copy read
+	// earlier would undo them
+	return r.db.WithContext(ctx).Omit("follower_count", "following_count", "post_count", "totp_last_step").Save(user).Error
+}
+
+// UseTOTPStep records a TOTP time step as used. It reports false if that
+// step or a later one has already been accepted.
+func (r *userRepository) UseTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
 }
 
 // Delete soft deletes user
-func (r *userRepository) Delete(id uint) error {
-	return r.db.Delete(&models.User{}, id).Error
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.User{}, id).Error
 }
 
 // EmailExists checks if email already exists
-func (r *userRepository) EmailExists(email string) (bool, error) {
+func (r *userRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.User{}).Where("email = ?", email).Count(&count).Error
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("email = ?", email).Count(&count).Error
 	return count > 0, err
 }
 
 // UsernameExists checks if username already exists
-func (r *userRepository) UsernameExists(username string) (bool, error) {
+func (r *userRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("username = ?", username).Count(&count).Error
 	return count > 0, err
 }
 
 // SearchUsers searches for users by name or username
-func (r *userRepository) SearchUsers(query string, limit, offset int) ([]models.User, error) {
+func (r *userRepository) SearchUsers(ctx context.Context, query string, limit, offset int) ([]models.User, error) {
 	var users []models.User
-	err := r.db.Where("username LIKE ? OR first_name LIKE ? OR last_name LIKE ?",
+	err := r.db.WithContext(ctx).Clauses(readReplica).Where("username LIKE ? OR first_name LIKE ? OR last_name LIKE ?",
 		"%"+query+"%", "%"+query+"%", "%"+query+"%").
 		Limit(limit).Offset(offset).Find(&users).Error
 	return users, err
 }
 
-// GetFollowers gets all users who follow the specified user
-func (r *userRepository) GetFollowers(userID uint) ([]models.User, error) {
+// GetFollowerIDs returns up to limit IDs of users who follow the specified
+// user, in ID order starting after afterID, so every follower can be visited
+// in batches
+func (r *userRepository) GetFollowerIDs(ctx context.Context, userID, afterID uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Clauses(readReplica).Model(&models.Follow{}).
+		Where("following_id = ? AND follower_id > ?", userID, afterID).
+		Order("follower_id").
+		Limit(limit).
+		Pluck("follower_id", &ids).Error
+	return ids, err
+}
+
+// List returns all users, including deactivated ones, newest first
+func (r *userRepository) List(ctx context.Context, limit, offset int) ([]models.User, error) {
 	var users []models.User
-	err := r.db.Table("users").
-		Joins("JOIN follows ON users.id = follows.follower_id").
-		Where("follows.following_id = ?", userID).
+	err := r.db.WithContext(ctx).Order("created_at DESC").
+		Limit(limit).Offset(offset).
 		Find(&users).Error
 	return users, err
 }
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+
+	"social-media-app/internal/models"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
+)
+
+// RateLimitAdmin inspects and clears the rate limit counters of a user
+type RateLimitAdmin interface {
+	UserLimits(ctx context.Context, userID uint, role models.Role) ([]models.RateLimitStatus, error)
+	ResetUser(ctx context.Context, userID uint, action string) error
+}
+
+type AdminService struct {
+	userRepo     repository.UserRepository
+	auditLogRepo repository.AuditLogRepository
+	auditLogger  AuditLogger
+	postService  *PostService
+	rateLimits   RateLimitAdmin
+}
+
+func NewAdminService(userRepo repository.UserRepository, auditLogRepo repository.AuditLogRepository, auditLogger AuditLogger, postService *PostService, rateLimits RateLimitAdmin) *AdminService {
+	return &AdminService{
+		userRepo:     userRepo,
+		auditLogRepo: auditLogRepo,
+		auditLogger:  auditLogger,
+		postService:  postService,
+		rateLimits:   rateLimits,
+	}
+}
+
+func (s *AdminService) ListUsers(ctx context.Context, limit, offset int) ([]models.AdminUserResponse, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.ListUsers")
+	defer span.End()
+
+	users, err := s.userRepo.List(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.AdminUserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, user.ToAdminResponse())
+	}
+
+	return responses, nil
+}
+
+// SetUserActive suspends or reinstates an account
+func (s *AdminService) SetUserActive(ctx context.Context, meta models.RequestMeta, userID uint, active bool) (*models.AdminUserResponse, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.SetUserActive")
+	defer span.End()
+
+	if meta.ActorID == userID {
+		return nil, domainerr.Forbidden("cannot change the status of your own account")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, "user not found")
+	}
+
+	previous := user.IsActive
+	user.IsActive = active
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	action := AuditActionSuspendUser
+	if active {
+		action = AuditActionUnsuspendUser
+	}
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     action,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+		Changes: map[string]models.AuditChange{
+			"is_active": {From: previous, To: active},
+		},
+	})
+
+	response := user.ToAdminResponse()
+	return &response, nil
+}
+
+func (s *AdminService) SetUserRole(ctx context.Context, meta models.RequestMeta, userID uint, role models.Role) (*models.AdminUserResponse, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.SetUserRole")
+	defer span.End()
+
+	if !role.IsValid() {
+		return nil, domainerr.Validation("invalid role")
+	}
+	if meta.ActorID == userID {
+		return nil, domainerr.Forbidden("cannot change your own role")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, "user not found")
+	}
+
+	previous := user.Role
+	user.Role = role
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionChangeRole,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+		Changes: map[string]models.AuditChange{
+			"role": {From: previous, To: role},
+		},
+	})
+
+	response := user.ToAdminResponse()
+	return &response, nil
+}
+
+// DeletePost removes any user's post regardless of ownership
+func (s *AdminService) DeletePost(ctx context.Context, meta models.RequestMeta, postID uint) error {
+	ctx, span := tracer.Start(ctx, "AdminService.DeletePost")
+	defer span.End()
+
+	return s.postService.Delete(ctx, meta, postID)
+}
+
+func (s *AdminService) ListAuditLogs(ctx context.Context, filter models.AuditLogFilter, limit, offset int) ([]models.AuditLog, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.ListAuditLogs")
+	defer span.End()
+
+	return s.auditLogRepo.List(ctx, filter, limit, offset)
+}
+
+// GetRateLimits reports how much of each rate limit the user has left,
+// using the limits for their role
+func (s *AdminService) GetRateLimits(ctx context.Context, userID uint) ([]models.RateLimitStatus, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.GetRateLimits")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, "user not found")
+	}
+
+	return s.rateLimits.UserLimits(ctx, user.ID, user.Role)
+}
+
+// ResetRateLimits clears the user's counters for one action, or for every
+// action if action is empty
+func (s *AdminService) ResetRateLimits(ctx context.Context, meta models.RequestMeta, userID uint, action string) error {
+	ctx, span := tracer.Start(ctx, "AdminService.ResetRateLimits")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return notFound(err, "user not found")
+	}
+
+	if err := s.rateLimits.ResetUser(ctx, user.ID, action); err != nil {
+		return err
+	}
+
+	reset := action
+	if reset == "" {
+		reset = "all"
+	}
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionResetRateLimit,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+		Changes: map[string]models.AuditChange{
+			"rate_limit": {From: reset, To: nil},
+		},
+	})
+	return nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"social-media-app/internal/logging"
+	"social-media-app/internal/models"
+	"social-media-app/internal/repository"
+)
+
+// Audit log actions
+const (
+	AuditActionRegister          = "auth.register"
+	AuditActionLogin             = "auth.login"
+	AuditActionLoginFailed       = "auth.login_failed"
+	AuditActionAccountLocked     = "auth.account_locked"
+	AuditActionOAuthLinked       = "auth.oauth_linked"
+	AuditActionTwoFactorEnabled  = "auth.2fa_enabled"
+	AuditActionTwoFactorDisabled = "auth.2fa_disabled"
+	AuditActionProfileUpdate     = "user.update_profile"
+	AuditActionPasswordChange    = "user.change_password"
+	AuditActionSuspendUser       = "user.suspend"
+	AuditActionUnsuspendUser     = "user.unsuspend"
+	AuditActionChangeRole        = "user.change_role"
+	AuditActionResetRateLimit    = "user.reset_rate_limit"
+	AuditActionFollow            = "user.follow"
+	AuditActionUnfollow          = "user.unfollow"
+	AuditActionDeletePost        = "post.delete"
+	AuditActionHidePost          = "post.hide"
+	AuditActionAssignReport      = "report.assign"
+	AuditActionResolveReport     = "report.resolve"
+	AuditActionDismissReport     = "report.dismiss"
+)
+
+// Audit target types
+const (
+	AuditTargetUser   = "user"
+	AuditTargetPost   = "post"
+	AuditTargetReport = "report"
+)
+
+// redactedValue replaces secrets in audit diffs
+const redactedValue = "[redacted]"
+
+// AuditEvent is a single action to record
+type AuditEvent struct {
+	Action     string
+	TargetType string
+	TargetID   uint
+	Changes    map[string]models.AuditChange
+}
+
+// AuditLogger records security-sensitive and moderation actions
+type AuditLogger interface {
+	Log(ctx context.Context, meta models.RequestMeta, event AuditEvent)
+}
+
+type auditLogger struct {
+	auditLogRepo repository.AuditLogRepository
+}
+
+// NewAuditLogger returns an AuditLogger that appends to the audit_logs table
+func NewAuditLogger(auditLogRepo repository.AuditLogRepository) AuditLogger {
+	return &auditLogger{auditLogRepo: auditLogRepo}
+}
+
+// Log writes the entry. Failures are logged rather than returned so that
+// auditing never blocks the action being audited.
+func (l *auditLogger) Log(ctx context.Context, meta models.RequestMeta, event AuditEvent) {
+	entry := &models.AuditLog{
+		ActorID:    meta.ActorID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IPAddress:  meta.ClientIP,
+		RequestID:  meta.RequestID,
+	}
+
+	if len(event.Changes) > 0 {
+		changes, err := json.Marshal(event.Changes)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to encode audit changes", "action", event.Action, "error", err)
+		} else {
+			entry.Changes = changes
+		}
+	}
+
+	// The audited action has already happened, so record it even if the request was cancelled
+	if err := l.auditLogRepo.Create(context.WithoutCancel(ctx), entry); err != nil {
+		logging.FromContext(ctx).Error("Failed to write audit log entry", "action", event.Action, "error", err)
+	}
+}
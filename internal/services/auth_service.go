@@ -1,41 +1,90 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
 
 	"social-media-app/internal/config"
+	"social-media-app/internal/logging"
+	"social-media-app/internal/metrics"
 	"social-media-app/internal/models"
+	"social-media-app/internal/ratelimit"
 	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
 	"social-media-app/internal/utils"
 )
 
+// Number of recovery codes issued when two-factor authentication is activated
+const recoveryCodeCount = 10
+
+var ErrInvalidCredentials = domainerr.Unauthorized("invalid email or password")
+
+// ErrLoginUnavailable is returned when login attempts can't be counted and
+// the lockout failure policy is closed
+var ErrLoginUnavailable = errors.New("login is temporarily unavailable")
+
+// AccountLockedError is returned while an account is locked out after repeated failed logins
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// dummyPasswordHash is compared against when the email is unknown so that
+// the response time does not reveal whether an account exists
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("dummy-password-for-timing")
+	return hash
+})
+
 type AuthService struct {
-	userRepo repository.UserRepository
-	config   *config.Config
+	userRepo         repository.UserRepository
+	attemptRepo      repository.LoginAttemptRepository
+	recoveryCodeRepo repository.RecoveryCodeRepository
+	notifier         SecurityNotifier
+	auditLogger      AuditLogger
+	keys             *utils.KeySet
+	config           *config.Config
 }
 
-func NewAuthService(userRepo repository.UserRepository, cfg *config.Config) *AuthService {
+func NewAuthService(userRepo repository.UserRepository, attemptRepo repository.LoginAttemptRepository, recoveryCodeRepo repository.RecoveryCodeRepository, notifier SecurityNotifier, auditLogger AuditLogger, keys *utils.KeySet, cfg *config.Config) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		config:   cfg,
+		userRepo:         userRepo,
+		attemptRepo:      attemptRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		notifier:         notifier,
+		auditLogger:      auditLogger,
+		keys:             keys,
+		config:           cfg,
 	}
 }
 
-func (s *AuthService) Register(req models.RegisterRequest) (*models.LoginResponse, error) {
-	emailExists, err := s.userRepo.EmailExists(req.Email)
+func (s *AuthService) Register(ctx context.Context, meta models.RequestMeta, req models.RegisterRequest) (*models.LoginResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Register")
+	defer span.End()
+
+	emailExists, err := s.userRepo.EmailExists(ctx, req.Email)
 	if err != nil {
 		return nil, err
 	}
 	if emailExists {
-		return nil, errors.New("email already exists")
+		return nil, domainerr.Conflict("email already exists")
 	}
 
-	usernameExists, err := s.userRepo.UsernameExists(req.Username)
+	usernameExists, err := s.userRepo.UsernameExists(ctx, req.Username)
 	if err != nil {
 		return nil, err
 	}
 	if usernameExists {
-		return nil, errors.New("username already exists")
+		return nil, domainerr.Conflict("username already exists")
 	}
 
 	hashedPassword, err := utils.HashPassword(req.Password)
@@ -52,42 +101,377 @@ func (s *AuthService) Register(req models.RegisterRequest) (*models.LoginRespons
 		IsActive:  true,
 	}
 
-	if err := s.userRepo.Create(user); err != nil {
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	metrics.Signups.WithLabelValues("password").Inc()
+
+	meta.ActorID = user.ID
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionRegister,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+	})
+
+	return s.issueToken(ctx, user)
+}
+
+func (s *AuthService) Login(ctx context.Context, meta models.RequestMeta, req models.LoginRequest) (*models.LoginResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Login")
+	defer span.End()
+
+	identifier := strings.ToLower(strings.TrimSpace(req.Email))
+
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		user = nil
+	}
+
+	if lockErr := s.recordAttempt(ctx, meta, identifier, user); lockErr != nil {
+		return nil, lockErr
+	}
+
+	// Always run a bcrypt comparison, even for unknown emails
+	passwordHash := dummyPasswordHash()
+	if user != nil {
+		passwordHash = user.Password
+	}
+	if !utils.CheckPasswordHash(req.Password, passwordHash) || user == nil {
+		s.recordFailedLogin(ctx, meta, user)
+		return nil, ErrInvalidCredentials
+	}
+
+	if !user.IsActive {
+		return nil, domainerr.Forbidden("account is deactivated")
+	}
+
+	// The password alone is not enough, wait for the second factor before resetting attempts
+	if !user.TwoFactorEnabled {
+		if err := s.attemptRepo.Reset(ctx, identifier); err != nil {
+			logging.FromContext(ctx).Error("Failed to reset login attempts", "error", err)
+		}
+	}
+
+	return s.completeLogin(ctx, meta, user)
+}
+
+// VerifyTwoFactor completes a login started with Login using a TOTP or recovery code
+func (s *AuthService) VerifyTwoFactor(ctx context.Context, meta models.RequestMeta, req models.TwoFactorVerifyRequest) (*models.LoginResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.VerifyTwoFactor")
+	defer span.End()
+
+	claims, err := utils.ValidateChallengeToken(req.ChallengeToken, s.keys)
+	if err != nil {
+		return nil, domainerr.Unauthorized("invalid or expired challenge")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil || !user.TwoFactorEnabled {
+		return nil, domainerr.Unauthorized("invalid or expired challenge")
+	}
+
+	// Second-factor guesses count towards the same lockout as passwords
+	identifier := strings.ToLower(strings.TrimSpace(user.Email))
+	if lockErr := s.recordAttempt(ctx, meta, identifier, user); lockErr != nil {
+		return nil, lockErr
+	}
+
+	valid, err := s.checkSecondFactor(ctx, user, req.Code)
+	if err != nil {
 		return nil, err
 	}
+	if !valid {
+		s.recordFailedLogin(ctx, meta, user)
+		return nil, domainerr.Unauthorized("invalid verification code")
+	}
+
+	if !user.IsActive {
+		return nil, domainerr.Forbidden("account is deactivated")
+	}
+
+	if err := s.attemptRepo.Reset(ctx, identifier); err != nil {
+		logging.FromContext(ctx).Error("Failed to reset login attempts", "error", err)
+	}
+
+	s.logLogin(ctx, meta, user)
+	return s.issueToken(ctx, user)
+}
 
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Email, s.config.JWT.Secret, s.config.JWT.Expiry)
+// EnrollTwoFactor generates a new TOTP secret. It is not enforced until
+// ActivateTwoFactor confirms the user's authenticator produces valid codes.
+func (s *AuthService) EnrollTwoFactor(ctx context.Context, userID uint) (*models.TwoFactorEnrollResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.EnrollTwoFactor")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	if user.TwoFactorEnabled {
+		return nil, domainerr.Conflict("two-factor authentication is already enabled")
+	}
 
-	return &models.LoginResponse{
-		User:  user.ToResponse(),
-		Token: token,
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPSecret = secret
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.config.Auth.TOTPIssuer, user.Email, secret),
 	}, nil
 }
 
-func (s *AuthService) Login(req models.LoginRequest) (*models.LoginResponse, error) {
-	user, err := s.userRepo.GetByEmail(req.Email)
+// ActivateTwoFactor turns on 2FA and returns a fresh set of recovery codes
+func (s *AuthService) ActivateTwoFactor(ctx context.Context, meta models.RequestMeta, userID uint, code string) (*models.TwoFactorActivateResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ActivateTwoFactor")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, domainerr.Conflict("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, domainerr.Conflict("two-factor enrollment has not been started")
+	}
+	valid, err := s.validateTOTP(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, domainerr.Validation("invalid verification code")
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hash, err := utils.HashPassword(code)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
 	}
 
+	if err := s.recoveryCodeRepo.ReplaceForUser(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+
+	user.TwoFactorEnabled = true
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionTwoFactorEnabled,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+	})
+
+	return &models.TwoFactorActivateResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTwoFactor requires both the password and a valid second factor
+func (s *AuthService) DisableTwoFactor(ctx context.Context, meta models.RequestMeta, userID uint, req models.TwoFactorDisableRequest) error {
+	ctx, span := tracer.Start(ctx, "AuthService.DisableTwoFactor")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return domainerr.Conflict("two-factor authentication is not enabled")
+	}
+
+	// A stolen session must not be a way around the login lockout, so
+	// password and second-factor guesses here count towards it too
+	identifier := strings.ToLower(strings.TrimSpace(user.Email))
+	if lockErr := s.recordAttempt(ctx, meta, identifier, user); lockErr != nil {
+		return lockErr
+	}
 	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		return nil, errors.New("invalid email or password")
+		s.recordFailedLogin(ctx, meta, user)
+		return domainerr.Validation("incorrect password")
 	}
 
-	if !user.IsActive {
-		return nil, errors.New("account is deactivated")
+	valid, err := s.checkSecondFactor(ctx, user, req.Code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		s.recordFailedLogin(ctx, meta, user)
+		return domainerr.Validation("invalid verification code")
+	}
+	if err := s.attemptRepo.Reset(ctx, identifier); err != nil {
+		logging.FromContext(ctx).Error("Failed to reset login attempts", "error", err)
+	}
+
+	if err := s.recoveryCodeRepo.DeleteForUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	user.TwoFactorEnabled = false
+	user.TOTPSecret = ""
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
 	}
 
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Email, s.config.JWT.Secret, s.config.JWT.Expiry)
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionTwoFactorDisabled,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+	})
+	return nil
+}
+
+// checkSecondFactor accepts a current TOTP code or consumes an unused recovery code
+func (s *AuthService) checkSecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	valid, err := s.validateTOTP(ctx, user, code)
+	if err != nil || valid {
+		return valid, err
+	}
+
+	recoveryCodes, err := s.recoveryCodeRepo.GetUnused(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	normalized := strings.ToLower(code)
+	for _, recoveryCode := range recoveryCodes {
+		if utils.CheckPasswordHash(normalized, recoveryCode.CodeHash) {
+			return s.recoveryCodeRepo.MarkUsed(ctx, recoveryCode.ID)
+		}
+	}
+
+	return false, nil
+}
+
+// validateTOTP accepts a TOTP code at most once. The matched time step is
+// claimed in the database, so a code can't be replayed within its window,
+// even by concurrent requests.
+func (s *AuthService) validateTOTP(ctx context.Context, user *models.User, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return s.userRepo.UseTOTPStep(ctx, user.ID, step)
+}
+
+// completeLogin is called once a user has proven the first factor. It issues a
+// 2FA challenge if the account requires one and an access token otherwise.
+func (s *AuthService) completeLogin(ctx context.Context, meta models.RequestMeta, user *models.User) (*models.LoginResponse, error) {
+	if user.TwoFactorEnabled {
+		challenge, err := utils.GenerateChallengeToken(user.ID, s.keys, s.config.Auth.ChallengeExpiry)
+		if err != nil {
+			return nil, err
+		}
+		return &models.LoginResponse{
+			RequiresTwoFactor: true,
+			ChallengeToken:    challenge,
+		}, nil
+	}
+
+	s.logLogin(ctx, meta, user)
+	return s.issueToken(ctx, user)
+}
+
+func (s *AuthService) logLogin(ctx context.Context, meta models.RequestMeta, user *models.User) {
+	meta.ActorID = user.ID
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionLogin,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+	})
+}
+
+func (s *AuthService) issueToken(ctx context.Context, user *models.User) (*models.LoginResponse, error) {
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Email, s.keys, s.config.JWT.Expiry)
 	if err != nil {
 		return nil, err
 	}
 
+	response := user.ToResponse()
 	return &models.LoginResponse{
-		User:  user.ToResponse(),
+		User:  &response,
 		Token: token,
 	}, nil
 }
+
+// recordAttempt counts a login attempt before the credentials are checked
+// and returns an AccountLockedError if the account is locked. Counting and
+// checking in one step means concurrent guesses can't all slip in before the
+// lockout. Each attempt past the limit doubles the lockout period.
+func (s *AuthService) recordAttempt(ctx context.Context, meta models.RequestMeta, identifier string, user *models.User) error {
+	// Finish recording even if the client disconnects, otherwise the lockout
+	// could be left unaudited
+	ctx = context.WithoutCancel(ctx)
+	cfg := s.config.Auth
+
+	attempt, err := s.attemptRepo.RecordAttempt(ctx, identifier, repository.LockoutPolicy{
+		MaxAttempts: cfg.MaxLoginAttempts,
+		Window:      cfg.AttemptWindow,
+		Base:        cfg.LockoutBase,
+		Max:         cfg.LockoutMax,
+	})
+	if err != nil {
+		if cfg.LockoutFailurePolicy == ratelimit.FailClosed {
+			logging.FromContext(ctx).Error("Failed to record login attempt, rejecting login", "error", err)
+			return ErrLoginUnavailable
+		}
+		logging.FromContext(ctx).Warn("Failed to record login attempt, allowing login", "error", err)
+		return nil
+	}
+	if attempt.Lockout <= 0 {
+		return nil
+	}
+
+	if attempt.Locked {
+		var targetID uint
+		if user != nil {
+			targetID = user.ID
+		}
+		s.auditLogger.Log(ctx, meta, AuditEvent{
+			Action:     AuditActionAccountLocked,
+			TargetType: AuditTargetUser,
+			TargetID:   targetID,
+			Changes: map[string]models.AuditChange{
+				"locked_until": {To: time.Now().Add(attempt.Lockout)},
+			},
+		})
+
+		if user != nil {
+			s.notifier.NotifySuspiciousLogin(ctx, user, meta.ClientIP, attempt.Attempts, attempt.Lockout)
+		}
+	}
+
+	return &AccountLockedError{RetryAfter: attempt.Lockout}
+}
+
+// recordFailedLogin audits a failed password or second-factor check
+func (s *AuthService) recordFailedLogin(ctx context.Context, meta models.RequestMeta, user *models.User) {
+	var targetID uint
+	if user != nil {
+		targetID = user.ID
+	}
+	s.auditLogger.Log(context.WithoutCancel(ctx), meta, AuditEvent{
+		Action:     AuditActionLoginFailed,
+		TargetType: AuditTargetUser,
+		TargetID:   targetID,
+	})
+}
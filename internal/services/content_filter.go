@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/logging"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
+)
+
+// FilterAction is the verdict of a content filter. Higher values are stricter.
+type FilterAction int
+
+const (
+	FilterAllow FilterAction = iota
+	// FilterFlag lets the content through but holds it for moderator review
+	FilterFlag
+	FilterReject
+)
+
+func (a FilterAction) String() string {
+	switch a {
+	case FilterFlag:
+		return "flag"
+	case FilterReject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// FilterResult is the verdict and the reasons behind it
+type FilterResult struct {
+	Action  FilterAction
+	Reasons []string
+}
+
+// ContentToCheck is the user generated content passed through the filters
+type ContentToCheck struct {
+	UserID   uint
+	PostID   uint // zero for new posts
+	Content  string
+	ImageURL string
+}
+
+// ContentFilter inspects post content before it is stored
+type ContentFilter interface {
+	Check(ctx context.Context, content ContentToCheck) FilterResult
+}
+
+// contentRejectedError reports each rejection reason as a violation on the content field
+func contentRejectedError(result FilterResult) error {
+	violations := make([]domainerr.Violation, 0, len(result.Reasons))
+	for _, reason := range result.Reasons {
+		violations = append(violations, domainerr.Violation{Field: "content", Rule: "content_filter", Message: reason})
+	}
+	return domainerr.Validation("post rejected by content filter", violations...)
+}
+
+// ContentFilterChain runs every filter and returns the strictest verdict
+// along with the reasons from every filter that did not allow the content
+type ContentFilterChain []ContentFilter
+
+func (chain ContentFilterChain) Check(ctx context.Context, content ContentToCheck) FilterResult {
+	ctx, span := tracer.Start(ctx, "ContentFilterChain.Check")
+	defer span.End()
+
+	result := FilterResult{Action: FilterAllow}
+	for _, filter := range chain {
+		r := filter.Check(ctx, content)
+		if r.Action == FilterAllow {
+			continue
+		}
+		if r.Action > result.Action {
+			result.Action = r.Action
+		}
+		result.Reasons = append(result.Reasons, r.Reasons...)
+	}
+	return result
+}
+
+// NewContentFilterChain builds the default filters from configuration
+func NewContentFilterChain(postRepo repository.PostRepository, cfg *config.Config) ContentFilterChain {
+	filterCfg := cfg.ContentFilter
+
+	var chain ContentFilterChain
+	if len(filterCfg.BlockedWords) > 0 {
+		chain = append(chain, NewWordListFilter(filterCfg.BlockedWords))
+	}
+	if len(filterCfg.BlockedDomains) > 0 {
+		chain = append(chain, NewDomainBlocklistFilter(filterCfg.BlockedDomains))
+	}
+	chain = append(chain, NewSpamFilter(postRepo, filterCfg))
+
+	return chain
+}
+
+// WordListFilter rejects content containing any blocked word
+type WordListFilter struct {
+	pattern *regexp.Regexp
+}
+
+func NewWordListFilter(words []string) *WordListFilter {
+	escaped := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			escaped = append(escaped, regexp.QuoteMeta(strings.ToLower(word)))
+		}
+	}
+
+	return &WordListFilter{
+		pattern: regexp.MustCompile(`(?i)\b(` + strings.Join(escaped, "|") + `)\b`),
+	}
+}
+
+func (f *WordListFilter) Check(ctx context.Context, content ContentToCheck) FilterResult {
+	if f.pattern.MatchString(content.Content) {
+		return FilterResult{Action: FilterReject, Reasons: []string{"content contains blocked language"}}
+	}
+	return FilterResult{Action: FilterAllow}
+}
+
+// DomainBlocklistFilter rejects links to blocked domains and their subdomains
+type DomainBlocklistFilter struct {
+	domains []string
+}
+
+func NewDomainBlocklistFilter(domains []string) *DomainBlocklistFilter {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			normalized = append(normalized, strings.TrimPrefix(domain, "."))
+		}
+	}
+	return &DomainBlocklistFilter{domains: normalized}
+}
+
+func (f *DomainBlocklistFilter) Check(ctx context.Context, content ContentToCheck) FilterResult {
+	links := extractLinks(content.Content)
+	if content.ImageURL != "" {
+		links = append(links, content.ImageURL)
+	}
+
+	var reasons []string
+	for _, link := range links {
+		host := linkHost(link)
+		for _, domain := range f.domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				reasons = append(reasons, fmt.Sprintf("links to blocked domain %s", domain))
+			}
+		}
+	}
+
+	if len(reasons) > 0 {
+		return FilterResult{Action: FilterReject, Reasons: reasons}
+	}
+	return FilterResult{Action: FilterAllow}
+}
+
+// SpamFilter rejects repeated identical posts and flags link-heavy posts
+type SpamFilter struct {
+	postRepo        repository.PostRepository
+	duplicateWindow time.Duration
+	maxLinks        int
+	maxLinkDensity  float64
+}
+
+func NewSpamFilter(postRepo repository.PostRepository, cfg config.ContentFilterConfig) *SpamFilter {
+	return &SpamFilter{
+		postRepo:        postRepo,
+		duplicateWindow: cfg.DuplicateWindow,
+		maxLinks:        cfg.MaxLinks,
+		maxLinkDensity:  cfg.MaxLinkDensity,
+	}
+}
+
+func (f *SpamFilter) Check(ctx context.Context, content ContentToCheck) FilterResult {
+	if f.duplicateWindow > 0 {
+		count, err := f.postRepo.CountRecentDuplicates(ctx, content.UserID, content.PostID, content.Content, time.Now().Add(-f.duplicateWindow))
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to check for duplicate posts", "error", err)
+		} else if count > 0 {
+			return FilterResult{Action: FilterReject, Reasons: []string{"you recently posted the same content"}}
+		}
+	}
+
+	links := len(extractLinks(content.Content))
+	if links == 0 {
+		return FilterResult{Action: FilterAllow}
+	}
+
+	if f.maxLinks > 0 && links > f.maxLinks {
+		return FilterResult{Action: FilterFlag, Reasons: []string{fmt.Sprintf("contains more than %d links", f.maxLinks)}}
+	}
+
+	words := len(strings.Fields(content.Content))
+	if f.maxLinkDensity > 0 && float64(links)/float64(words) > f.maxLinkDensity {
+		return FilterResult{Action: FilterFlag, Reasons: []string{"contains mostly links"}}
+	}
+
+	return FilterResult{Action: FilterAllow}
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+
+func extractLinks(text string) []string {
+	return linkPattern.FindAllString(text, -1)
+}
+
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
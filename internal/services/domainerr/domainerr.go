@@ -0,0 +1,72 @@
+// Package domainerr defines the errors services return for expected failures,
+// so handlers can map them to HTTP status codes without matching on messages.
+package domainerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies a domain error
+type Kind string
+
+const (
+	KindNotFound   Kind = "not_found"
+	KindConflict   Kind = "conflict"
+	KindForbidden  Kind = "forbidden"
+	KindValidation Kind = "validation"
+	// KindUnauthorized is for failed authentication, e.g. a wrong password
+	KindUnauthorized Kind = "unauthorized"
+)
+
+// Violation is a single broken rule, attributed to a request field when possible
+type Violation struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Error is an expected failure whose message is safe to show to clients
+type Error struct {
+	Kind       Kind
+	Message    string
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NotFound reports that the requested resource does not exist
+func NotFound(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// Conflict reports that the request clashes with the resource's current state
+func Conflict(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// Forbidden reports that the caller may not perform the action
+func Forbidden(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// Unauthorized reports that the caller could not be authenticated
+func Unauthorized(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindUnauthorized, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validation reports that the request breaks a business rule
+func Validation(message string, violations ...Violation) *Error {
+	return &Error{Kind: KindValidation, Message: message, Violations: violations}
+}
+
+// As returns the domain error in err's chain, if any
+func As(err error) (*Error, bool) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"social-media-app/internal/services/domainerr"
+)
+
+// notFound maps a repository lookup error to a NotFound error with message
+// when the record doesn't exist. Any other error is returned as is, so
+// database failures aren't reported as a missing record.
+func notFound(err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domainerr.NotFound(message)
+	}
+	return err
+}
@@ -1,36 +1,43 @@
 package services
 
 import (
-	"errors"
+	"context"
 	"strings"
 
+	"social-media-app/internal/metrics"
 	"social-media-app/internal/models"
 	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
 )
 
 type FollowService struct {
-	followRepo repository.FollowRepository
-	userRepo   repository.UserRepository
-	cacheRepo  repository.CacheRepository
+	followRepo  repository.FollowRepository
+	userRepo    repository.UserRepository
+	cacheRepo   repository.CacheRepository
+	auditLogger AuditLogger
 }
 
-func NewFollowService(followRepo repository.FollowRepository, userRepo repository.UserRepository, cacheRepo repository.CacheRepository) *FollowService {
+func NewFollowService(followRepo repository.FollowRepository, userRepo repository.UserRepository, cacheRepo repository.CacheRepository, auditLogger AuditLogger) *FollowService {
 	return &FollowService{
-		followRepo: followRepo,
-		userRepo:   userRepo,
-		cacheRepo:  cacheRepo,
+		followRepo:  followRepo,
+		userRepo:    userRepo,
+		cacheRepo:   cacheRepo,
+		auditLogger: auditLogger,
 	}
 }
 
-func (s *FollowService) FollowUser(followerID, followingID uint) error {
+func (s *FollowService) FollowUser(ctx context.Context, meta models.RequestMeta, followerID, followingID uint) error {
+	ctx, span := tracer.Start(ctx, "FollowService.FollowUser")
+	defer span.End()
+
 	if followerID == followingID {
-		return errors.New("cannot follow yourself")
+		return domainerr.Validation("cannot follow yourself")
 	}
 
 	// Check if target user exists
-	_, err := s.userRepo.GetByID(followingID)
+	_, err := s.userRepo.GetByID(ctx, followingID)
 	if err != nil {
-		return errors.New("user not found")
+		return notFound(err, "user not found")
 	}
 
 	// Try to create the follow relationship
@@ -39,7 +46,7 @@ func (s *FollowService) FollowUser(followerID, followingID uint) error {
 		FollowingID: followingID,
 	}
 
-	err = s.followRepo.Create(follow)
+	err = s.followRepo.Create(ctx, follow)
 	if err != nil {
 		// Handle unique constraint violations gracefully
 		errorStr := strings.ToLower(err.Error())
@@ -53,35 +60,57 @@ func (s *FollowService) FollowUser(followerID, followingID uint) error {
 		}
 		return err
 	}
+	metrics.Follows.WithLabelValues(metrics.ActionFollow).Inc()
 
 	// Clear cache after successful follow
-	s.cacheRepo.DeleteTimeline(followerID)
+	s.cacheRepo.DeleteTimeline(ctx, followerID)
+
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionFollow,
+		TargetType: AuditTargetUser,
+		TargetID:   followingID,
+	})
 	return nil
 }
 
-func (s *FollowService) UnfollowUser(followerID, followingID uint) error {
+func (s *FollowService) UnfollowUser(ctx context.Context, meta models.RequestMeta, followerID, followingID uint) error {
+	ctx, span := tracer.Start(ctx, "FollowService.UnfollowUser")
+	defer span.End()
+
 	if followerID == followingID {
-		return errors.New("cannot unfollow yourself")
+		return domainerr.Validation("cannot unfollow yourself")
 	}
 
 	// Just attempt to delete - if it doesn't exist, that's fine
-	err := s.followRepo.Delete(followerID, followingID)
+	err := s.followRepo.Delete(ctx, followerID, followingID)
 	if err != nil {
 		return err
 	}
+	metrics.Follows.WithLabelValues(metrics.ActionUnfollow).Inc()
 
 	// Clear cache after operation
-	s.cacheRepo.DeleteTimeline(followerID)
+	s.cacheRepo.DeleteTimeline(ctx, followerID)
+
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionUnfollow,
+		TargetType: AuditTargetUser,
+		TargetID:   followingID,
+	})
 	return nil
 }
 
-func (s *FollowService) GetFollowers(userID uint) ([]models.FollowResponse, error) {
-	follows, err := s.followRepo.GetFollowers(userID)
+// GetFollowers returns a page of the user's followers, flagged relative to
+// the viewer
+func (s *FollowService) GetFollowers(ctx context.Context, viewerID, userID uint, limit, offset int) ([]models.FollowResponse, error) {
+	ctx, span := tracer.Start(ctx, "FollowService.GetFollowers")
+	defer span.End()
+
+	follows, err := s.followRepo.GetFollowers(ctx, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 
-	var responses []models.FollowResponse
+	responses := make([]models.FollowResponse, 0, len(follows))
 	for _, follow := range follows {
 		responses = append(responses, models.FollowResponse{
 			User:      follow.Follower.ToResponse(),
@@ -89,16 +118,21 @@ func (s *FollowService) GetFollowers(userID uint) ([]models.FollowResponse, erro
 		})
 	}
 
-	return responses, nil
+	return responses, s.setFollowFlags(ctx, viewerID, responses)
 }
 
-func (s *FollowService) GetFollowing(userID uint) ([]models.FollowResponse, error) {
-	follows, err := s.followRepo.GetFollowing(userID)
+// GetFollowing returns a page of the users the user follows, flagged
+// relative to the viewer
+func (s *FollowService) GetFollowing(ctx context.Context, viewerID, userID uint, limit, offset int) ([]models.FollowResponse, error) {
+	ctx, span := tracer.Start(ctx, "FollowService.GetFollowing")
+	defer span.End()
+
+	follows, err := s.followRepo.GetFollowing(ctx, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 
-	var responses []models.FollowResponse
+	responses := make([]models.FollowResponse, 0, len(follows))
 	for _, follow := range follows {
 		responses = append(responses, models.FollowResponse{
 			User:      follow.Following.ToResponse(),
@@ -106,5 +140,38 @@ func (s *FollowService) GetFollowing(userID uint) ([]models.FollowResponse, erro
 		})
 	}
 
-	return responses, nil
+	return responses, s.setFollowFlags(ctx, viewerID, responses)
+}
+
+// setFollowFlags marks which listed users the viewer follows and which
+// follow the viewer, with one query for each
+func (s *FollowService) setFollowFlags(ctx context.Context, viewerID uint, responses []models.FollowResponse) error {
+	userIDs := make([]uint, len(responses))
+	for i, response := range responses {
+		userIDs[i] = response.User.ID
+	}
+
+	followed, err := s.followRepo.FollowedIDs(ctx, viewerID, userIDs)
+	if err != nil {
+		return err
+	}
+	followers, err := s.followRepo.FollowerIDs(ctx, viewerID, userIDs)
+	if err != nil {
+		return err
+	}
+
+	youFollow := make(map[uint]bool, len(followed))
+	for _, id := range followed {
+		youFollow[id] = true
+	}
+	followsYou := make(map[uint]bool, len(followers))
+	for _, id := range followers {
+		followsYou[id] = true
+	}
+
+	for i := range responses {
+		responses[i].YouFollow = youFollow[responses[i].User.ID]
+		responses[i].FollowsYou = followsYou[responses[i].User.ID]
+	}
+	return nil
 }
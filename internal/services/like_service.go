@@ -1,38 +1,47 @@
 package services
 
 import (
-	"errors"
+	"context"
+	"strings"
+
+	"social-media-app/internal/metrics"
 	"social-media-app/internal/models"
 	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
 )
 
 type LikeService struct {
-	likeRepo repository.LikeRepository
-	postRepo repository.PostRepository
+	likeRepo  repository.LikeRepository
+	postRepo  repository.PostRepository
+	cacheRepo repository.CacheRepository
 }
 
-func NewLikeService(likeRepo repository.LikeRepository, postRepo repository.PostRepository) *LikeService {
+func NewLikeService(likeRepo repository.LikeRepository, postRepo repository.PostRepository, cacheRepo repository.CacheRepository) *LikeService {
 	return &LikeService{
-		likeRepo: likeRepo,
-		postRepo: postRepo,
+		likeRepo:  likeRepo,
+		postRepo:  postRepo,
+		cacheRepo: cacheRepo,
 	}
 }
 
-func (s *LikeService) LikePost(likedBy, postID uint) error {
+func (s *LikeService) LikePost(ctx context.Context, likedBy, postID uint) error {
+	ctx, span := tracer.Start(ctx, "LikeService.LikePost")
+	defer span.End()
+
 	// Check if post exists
-	_, err := s.postRepo.GetByID(postID)
+	_, err := s.postRepo.GetByID(ctx, postID)
 	if err != nil {
-		return errors.New("post not found")
+		return notFound(err, "post not found")
 	}
 
 	// Check if user has already liked this post
-	exists, err := s.likeRepo.Exists(likedBy, postID)
+	exists, err := s.likeRepo.Exists(ctx, likedBy, postID)
 	if err != nil {
 		return err
 	}
 
 	if exists {
-		return errors.New("post already liked by this user")
+		return domainerr.Conflict("post already liked by this user")
 	}
 
 	// Create new like record
@@ -42,42 +51,80 @@ func (s *LikeService) LikePost(likedBy, postID uint) error {
 		LikedBy: likedBy, // New field to track who liked
 	}
 
-	return s.likeRepo.Create(like)
+	if err := s.likeRepo.Create(ctx, like); err != nil {
+		// A concurrent like of the same post can get past the check above
+		// and hit the unique constraint instead
+		errorStr := strings.ToLower(err.Error())
+		if strings.Contains(errorStr, "unique") || strings.Contains(errorStr, "duplicate") {
+			return domainerr.Conflict("post already liked by this user")
+		}
+		return err
+	}
+	s.invalidatePost(ctx, postID)
+	metrics.Likes.WithLabelValues(metrics.ActionLike).Inc()
+	return nil
 }
 
-func (s *LikeService) UnlikePost(likedBy, postID uint) error {
+func (s *LikeService) UnlikePost(ctx context.Context, likedBy, postID uint) error {
+	ctx, span := tracer.Start(ctx, "LikeService.UnlikePost")
+	defer span.End()
+
 	// Check if post exists
-	_, err := s.postRepo.GetByID(postID)
+	_, err := s.postRepo.GetByID(ctx, postID)
 	if err != nil {
-		return errors.New("post not found")
+		return notFound(err, "post not found")
 	}
 
 	// Check if user has liked this post
-	exists, err := s.likeRepo.Exists(likedBy, postID)
+	exists, err := s.likeRepo.Exists(ctx, likedBy, postID)
 	if err != nil {
 		return err
 	}
 
 	if !exists {
-		return errors.New("post not liked by this user")
+		return domainerr.NotFound("post not liked by this user")
 	}
 
 	// Remove the like
-	return s.likeRepo.Delete(likedBy, postID)
+	if err := s.likeRepo.Delete(ctx, likedBy, postID); err != nil {
+		return err
+	}
+	s.invalidatePost(ctx, postID)
+	metrics.Likes.WithLabelValues(metrics.ActionUnlike).Inc()
+	return nil
 }
 
-func (s *LikeService) GetPostLikes(postID uint) ([]models.Like, error) {
-	return s.likeRepo.GetByPostID(postID)
+func (s *LikeService) GetPostLikes(ctx context.Context, postID uint) ([]models.Like, error) {
+	ctx, span := tracer.Start(ctx, "LikeService.GetPostLikes")
+	defer span.End()
+
+	return s.likeRepo.GetByPostID(ctx, postID)
+}
+
+func (s *LikeService) GetLikeCount(ctx context.Context, postID uint) (int64, error) {
+	ctx, span := tracer.Start(ctx, "LikeService.GetLikeCount")
+	defer span.End()
+
+	return s.likeRepo.GetLikeCount(ctx, postID)
 }
 
-func (s *LikeService) GetLikeCount(postID uint) (int64, error) {
-	return s.likeRepo.GetLikeCount(postID)
+func (s *LikeService) IsPostLikedByUser(ctx context.Context, postID, userID uint) (bool, error) {
+	ctx, span := tracer.Start(ctx, "LikeService.IsPostLikedByUser")
+	defer span.End()
+
+	return s.likeRepo.Exists(ctx, userID, postID)
 }
 
-func (s *LikeService) IsPostLikedByUser(postID, userID uint) (bool, error) {
-	return s.likeRepo.Exists(userID, postID)
+func (s *LikeService) GetLikedUserIDs(ctx context.Context, postID uint) ([]uint, error) {
+	ctx, span := tracer.Start(ctx, "LikeService.GetLikedUserIDs")
+	defer span.End()
+
+	return s.likeRepo.GetLikedUserIDs(ctx, postID)
 }
 
-func (s *LikeService) GetLikedUserIDs(postID uint) ([]uint, error) {
-	return s.likeRepo.GetLikedUserIDs(postID)
+// invalidatePost drops the cached post so its like count is reloaded.
+// Timelines only cache post IDs and is_liked is looked up per viewer, so
+// nothing else needs clearing.
+func (s *LikeService) invalidatePost(ctx context.Context, postID uint) {
+	s.cacheRepo.DeletePostCache(context.WithoutCancel(ctx), postID)
 }
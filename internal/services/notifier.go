@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"social-media-app/internal/logging"
+	"social-media-app/internal/models"
+)
+
+// SecurityNotifier is told about suspicious activity on an account
+type SecurityNotifier interface {
+	NotifySuspiciousLogin(ctx context.Context, user *models.User, clientIP string, failedAttempts int64, lockout time.Duration)
+}
+
+// LogNotifier reports security events to the application log
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) NotifySuspiciousLogin(ctx context.Context, user *models.User, clientIP string, failedAttempts int64, lockout time.Duration) {
+	logging.FromContext(ctx).Warn("Suspicious login activity",
+		"target_user_id", user.ID, "email", user.Email, "failed_attempts", failedAttempts,
+		"client_ip", clientIP, "lockout", lockout.String())
+}
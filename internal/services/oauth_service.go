@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/logging"
+	"social-media-app/internal/metrics"
+	"social-media-app/internal/models"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
+	"social-media-app/internal/utils"
+)
+
+var ErrUnknownOAuthProvider = domainerr.NotFound("unknown OAuth provider")
+
+// Timeout for calls to the provider (discovery, token exchange, JWKS)
+const oauthProviderTimeout = 10 * time.Second
+
+var usernameDisallowedChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// oidcClaims are the ID token claims used to find or create a user
+type oidcClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	Name              string `json:"name"`
+	Picture           string `json:"picture"`
+}
+
+type oidcProvider struct {
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// OAuthService signs users in through OpenID Connect providers using the
+// authorization code flow with PKCE
+type OAuthService struct {
+	authService  *AuthService
+	userRepo     repository.UserRepository
+	identityRepo repository.ExternalIdentityRepository
+	stateRepo    repository.OAuthStateRepository
+	config       *config.Config
+
+	mu        sync.Mutex
+	providers map[string]*oidcProvider
+	discovery singleflight.Group
+}
+
+func NewOAuthService(authService *AuthService, userRepo repository.UserRepository, identityRepo repository.ExternalIdentityRepository, stateRepo repository.OAuthStateRepository, cfg *config.Config) *OAuthService {
+	return &OAuthService{
+		authService:  authService,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		stateRepo:    stateRepo,
+		config:       cfg,
+		providers:    make(map[string]*oidcProvider),
+	}
+}
+
+// AuthorizationURL starts a login and returns the provider URL to redirect the user to
+func (s *OAuthService) AuthorizationURL(ctx context.Context, providerName string) (string, error) {
+	ctx, span := tracer.Start(ctx, "OAuthService.AuthorizationURL")
+	defer span.End()
+
+	provider, err := s.getProvider(ctx, providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = s.stateRepo.Save(ctx, state, models.OAuthState{
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+	}, s.config.OAuth.StateTTL)
+	if err != nil {
+		return "", err
+	}
+
+	return provider.oauth2.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce)), nil
+}
+
+// HandleCallback exchanges the authorization code, verifies the ID token and
+// logs in the linked user, linking or creating one if necessary
+func (s *OAuthService) HandleCallback(ctx context.Context, meta models.RequestMeta, providerName string, req models.OAuthCallbackRequest) (*models.LoginResponse, error) {
+	ctx, span := tracer.Start(ctx, "OAuthService.HandleCallback")
+	defer span.End()
+
+	if req.Error != "" {
+		return nil, domainerr.Unauthorized("provider returned an error: %s %s", req.Error, req.ErrorDescription)
+	}
+	if req.Code == "" {
+		return nil, domainerr.Unauthorized("missing authorization code")
+	}
+
+	state, err := s.stateRepo.Consume(ctx, req.State)
+	if err != nil || state.Provider != providerName {
+		return nil, domainerr.Unauthorized("invalid or expired OAuth state")
+	}
+
+	provider, err := s.getProvider(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	providerCtx, cancel := context.WithTimeout(ctx, oauthProviderTimeout)
+	defer cancel()
+
+	token, err := provider.oauth2.Exchange(providerCtx, req.Code, oauth2.VerifierOption(state.CodeVerifier))
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to exchange authorization code", "provider", providerName, "error", err)
+		return nil, domainerr.Unauthorized("failed to exchange authorization code")
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, domainerr.Unauthorized("provider did not return an ID token")
+	}
+
+	idToken, err := provider.verifier.Verify(providerCtx, rawIDToken)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Invalid ID token", "provider", providerName, "error", err)
+		return nil, domainerr.Unauthorized("invalid ID token")
+	}
+	if idToken.Nonce != state.Nonce {
+		return nil, domainerr.Unauthorized("invalid ID token nonce")
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	user, err := s.findOrCreateUser(ctx, meta, providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, domainerr.Forbidden("account is deactivated")
+	}
+
+	return s.authService.completeLogin(ctx, meta, user)
+}
+
+// findOrCreateUser resolves the user for an external identity. Identities are
+// linked to existing accounts only when the provider has verified the email.
+func (s *OAuthService) findOrCreateUser(ctx context.Context, meta models.RequestMeta, providerName string, claims oidcClaims) (*models.User, error) {
+	// Only a missing row means the identity isn't linked yet. Treating other
+	// errors the same could create a second account or identity.
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, providerName, claims.Subject)
+	if err == nil {
+		return s.userRepo.GetByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, domainerr.Validation("provider did not return an email address")
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		if !claims.EmailVerified {
+			return nil, domainerr.Conflict("an account with this email already exists, log in with your password instead")
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user, err = s.createUser(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	identity = &models.ExternalIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		return nil, err
+	}
+
+	meta.ActorID = user.ID
+	s.authService.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionOAuthLinked,
+		TargetType: AuditTargetUser,
+		TargetID:   user.ID,
+		Changes: map[string]models.AuditChange{
+			"provider": {To: providerName},
+		},
+	})
+
+	return user, nil
+}
+
+func (s *OAuthService) createUser(ctx context.Context, claims oidcClaims) (*models.User, error) {
+	username, err := s.availableUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users created through a provider have no usable password until they set one
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(claims.Name, " ")
+	}
+
+	user := &models.User{
+		Username:  username,
+		Email:     claims.Email,
+		Password:  hashedPassword,
+		FirstName: truncate(firstName, 50),
+		LastName:  truncate(lastName, 50),
+		Avatar:    truncate(claims.Picture, 255),
+		IsActive:  true,
+	}
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	metrics.Signups.WithLabelValues("oauth").Inc()
+	return user, nil
+}
+
+// availableUsername derives a username from the claims, adding a random
+// suffix if the preferred one is taken
+func (s *OAuthService) availableUsername(ctx context.Context, claims oidcClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameDisallowedChars.ReplaceAllString(strings.ToLower(base), "")
+	base = truncate(base, 40)
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		exists, err := s.userRepo.UsernameExists(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+
+		suffix, err := utils.GenerateRandomToken(4)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + usernameDisallowedChars.ReplaceAllString(strings.ToLower(suffix), "")
+	}
+
+	return "", errors.New("could not find an available username")
+}
+
+// getProvider lazily runs OIDC discovery so an unreachable provider does not block startup
+func (s *OAuthService) getProvider(ctx context.Context, name string) (*oidcProvider, error) {
+	cfg, ok := s.config.OAuth.Providers[name]
+	if !ok {
+		return nil, ErrUnknownOAuthProvider
+	}
+
+	s.mu.Lock()
+	provider, ok := s.providers[name]
+	s.mu.Unlock()
+	if ok {
+		return provider, nil
+	}
+
+	// Discovery runs outside the lock, once per provider however many
+	// requests are waiting on it, so a slow provider doesn't hold up others
+	discovered, err, _ := s.discovery.Do(name, func() (interface{}, error) {
+		// Shared by every waiting request, so it must outlive this one
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), oauthProviderTimeout)
+		defer cancel()
+
+		discovered, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover OAuth provider %s: %w", name, err)
+		}
+
+		provider := &oidcProvider{
+			oauth2: oauth2.Config{
+				ClientID:     cfg.ClientID,
+				ClientSecret: cfg.ClientSecret,
+				RedirectURL:  cfg.RedirectURL,
+				Endpoint:     discovered.Endpoint(),
+				Scopes:       cfg.Scopes,
+			},
+			verifier: discovered.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		}
+
+		s.mu.Lock()
+		s.providers[name] = provider
+		s.mu.Unlock()
+		return provider, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return discovered.(*oidcProvider), nil
+}
+
+func truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,626 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/models"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
+	"social-media-app/internal/utils"
+)
+
+const (
+	testProvider = "fake"
+	testClientID = "postly-test"
+	testKeyID    = "issuer-key"
+)
+
+// fakeIssuer is a minimal OpenID Connect provider serving discovery, JWKS and
+// an authorization code token endpoint that enforces PKCE
+type fakeIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// stall, when set, holds discovery requests until it is closed, after
+	// signalling stalled
+	stall   chan struct{}
+	stalled chan struct{}
+
+	mu     sync.Mutex
+	grants map[string]fakeGrant
+}
+
+// fakeGrant is what the provider remembers about an issued authorization code
+type fakeGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+	// signer overrides the published key, to produce an invalid signature
+	signer *rsa.PrivateKey
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	issuer := &fakeIssuer{
+		key:    newRSAKey(t),
+		grants: make(map[string]fakeGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks", issuer.jwks)
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func (i *fakeIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	if i.stall != nil {
+		i.stalled <- struct{}{}
+		<-i.stall
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                i.server.URL,
+		"authorization_endpoint":                i.server.URL + "/authorize",
+		"token_endpoint":                        i.server.URL + "/token",
+		"jwks_uri":                              i.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *fakeIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (i *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	// Codes are single use
+	i.mu.Lock()
+	grant, ok := i.grants[r.Form.Get("code")]
+	delete(i.grants, r.Form.Get("code"))
+	i.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	signer := i.key
+	if grant.signer != nil {
+		signer = grant.signer
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	idToken.Header["kid"] = testKeyID
+	signed, err := idToken.SignedString(signer)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// grant issues an authorization code for the claims, as the provider would
+// after the user signs in
+func (i *fakeIssuer) grant(code string, grant fakeGrant) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.grants[code] = grant
+}
+
+// fakeUserRepo keeps users in memory. Methods the OAuth flow doesn't use are
+// left to the embedded interface and panic if called.
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users []*models.User
+}
+
+func (r *fakeUserRepo) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.ID = uint(len(r.users) + 1)
+	r.users = append(r.users, user)
+	return nil
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepo) UsernameExists(ctx context.Context, username string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type fakeIdentityRepo struct {
+	mu         sync.Mutex
+	identities []models.ExternalIdentity
+	// err is returned by lookups when set, to simulate a database failure
+	err error
+}
+
+func (r *fakeIdentityRepo) Create(ctx context.Context, identity *models.ExternalIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity.ID = uint(len(r.identities) + 1)
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *fakeIdentityRepo) GetByProviderSubject(ctx context.Context, provider, subject string) (*models.ExternalIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeIdentityRepo) GetByUserID(ctx context.Context, userID uint) ([]models.ExternalIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var identities []models.ExternalIdentity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+type fakeStateRepo struct {
+	mu     sync.Mutex
+	states map[string]models.OAuthState
+}
+
+func (r *fakeStateRepo) Save(ctx context.Context, state string, data models.OAuthState, expiry time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state] = data
+	return nil
+}
+
+func (r *fakeStateRepo) Consume(ctx context.Context, state string) (*models.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.states[state]
+	if !ok {
+		return nil, errors.New("state not found")
+	}
+	delete(r.states, state)
+	return &data, nil
+}
+
+type nopAuditLogger struct{}
+
+func (nopAuditLogger) Log(ctx context.Context, meta models.RequestMeta, event AuditEvent) {}
+
+type oauthTest struct {
+	service    *OAuthService
+	issuer     *fakeIssuer
+	users      *fakeUserRepo
+	identities *fakeIdentityRepo
+}
+
+func newOAuthTest(t *testing.T) *oauthTest {
+	t.Helper()
+
+	issuer := newFakeIssuer(t)
+	cfg := &config.Config{
+		JWT: config.JWTConfig{Expiry: time.Hour},
+		OAuth: config.OAuthConfig{
+			Providers: map[string]config.OAuthProviderConfig{
+				testProvider: {
+					Name:         testProvider,
+					IssuerURL:    issuer.server.URL,
+					ClientID:     testClientID,
+					ClientSecret: "secret",
+					RedirectURL:  "http://localhost/api/auth/oauth/fake/callback",
+					Scopes:       []string{"openid", "email", "profile"},
+				},
+			},
+			StateTTL: time.Minute,
+		},
+	}
+
+	users := &fakeUserRepo{}
+	identities := &fakeIdentityRepo{}
+	states := &fakeStateRepo{states: make(map[string]models.OAuthState)}
+	authService := NewAuthService(users, nil, nil, nil, nopAuditLogger{}, newTestKeySet(t), cfg)
+
+	return &oauthTest{
+		service:    NewOAuthService(authService, users, identities, states, cfg),
+		issuer:     issuer,
+		users:      users,
+		identities: identities,
+	}
+}
+
+// authorize starts a login, has the fake provider issue a code for the
+// claims and returns the callback the provider would redirect back with.
+// The nonce and standard claims are filled in unless already set.
+func (o *oauthTest) authorize(t *testing.T, claims jwt.MapClaims, signer *rsa.PrivateKey) models.OAuthCallbackRequest {
+	t.Helper()
+
+	authURL, err := o.service.AuthorizationURL(context.Background(), testProvider)
+	if err != nil {
+		t.Fatalf("AuthorizationURL: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	query := parsed.Query()
+	if method := query.Get("code_challenge_method"); method != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", method)
+	}
+
+	now := time.Now()
+	defaults := jwt.MapClaims{
+		"iss":   o.issuer.server.URL,
+		"aud":   testClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for key, value := range defaults {
+		if _, ok := claims[key]; !ok {
+			claims[key] = value
+		}
+	}
+
+	code := "code-" + query.Get("state")
+	o.issuer.grant(code, fakeGrant{
+		challenge: query.Get("code_challenge"),
+		claims:    claims,
+		signer:    signer,
+	})
+
+	return models.OAuthCallbackRequest{Code: code, State: query.Get("state")}
+}
+
+func (o *oauthTest) callback(req models.OAuthCallbackRequest) (*models.LoginResponse, error) {
+	return o.service.HandleCallback(context.Background(), models.RequestMeta{}, testProvider, req)
+}
+
+func TestOAuthCallbackCreatesUser(t *testing.T) {
+	o := newOAuthTest(t)
+
+	req := o.authorize(t, jwt.MapClaims{
+		"sub":                "subject-1",
+		"email":              "new@example.com",
+		"email_verified":     true,
+		"preferred_username": "New.User",
+		"given_name":         "New",
+		"family_name":        "User",
+	}, nil)
+
+	resp, err := o.callback(req)
+	if err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	if resp.Token == "" {
+		t.Fatal("expected an access token")
+	}
+
+	if len(o.users.users) != 1 {
+		t.Fatalf("created %d users, want 1", len(o.users.users))
+	}
+	user := o.users.users[0]
+	if user.Email != "new@example.com" || user.Username != "newuser" || user.FirstName != "New" || user.LastName != "User" {
+		t.Errorf("created user = %+v", user)
+	}
+	if resp.User.ID != user.ID {
+		t.Errorf("logged in as user %d, want %d", resp.User.ID, user.ID)
+	}
+	assertLinked(t, o.identities, user.ID, "subject-1")
+}
+
+func TestOAuthCallbackLogsInLinkedIdentity(t *testing.T) {
+	o := newOAuthTest(t)
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{"sub": "subject-1", "email": "new@example.com", "email_verified": true}
+	}
+
+	first, err := o.callback(o.authorize(t, claims(), nil))
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	second, err := o.callback(o.authorize(t, claims(), nil))
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+
+	if second.User.ID != first.User.ID || len(o.users.users) != 1 || len(o.identities.identities) != 1 {
+		t.Errorf("second login created another account or identity")
+	}
+}
+
+func TestOAuthCallbackRejectsWrongPKCEVerifier(t *testing.T) {
+	o := newOAuthTest(t)
+
+	req := o.authorize(t, jwt.MapClaims{"sub": "subject-1", "email": "new@example.com"}, nil)
+	// The provider saw a challenge that doesn't match the stored verifier
+	o.issuer.mu.Lock()
+	grant := o.issuer.grants[req.Code]
+	grant.challenge = "not-the-challenge"
+	o.issuer.grants[req.Code] = grant
+	o.issuer.mu.Unlock()
+
+	_, err := o.callback(req)
+	assertKind(t, err, domainerr.KindUnauthorized)
+	if len(o.users.users) != 0 {
+		t.Error("a user was created without a successful exchange")
+	}
+}
+
+func TestOAuthCallbackRejectsUnknownState(t *testing.T) {
+	o := newOAuthTest(t)
+
+	req := o.authorize(t, jwt.MapClaims{"sub": "subject-1", "email": "new@example.com"}, nil)
+	req.State = "forged-state"
+
+	_, err := o.callback(req)
+	assertKind(t, err, domainerr.KindUnauthorized)
+}
+
+func TestOAuthCallbackRejectsReplayedState(t *testing.T) {
+	o := newOAuthTest(t)
+
+	req := o.authorize(t, jwt.MapClaims{"sub": "subject-1", "email": "new@example.com", "email_verified": true}, nil)
+	if _, err := o.callback(req); err != nil {
+		t.Fatalf("first callback: %v", err)
+	}
+
+	_, err := o.callback(req)
+	assertKind(t, err, domainerr.KindUnauthorized)
+}
+
+func TestOAuthCallbackRejectsBadIDTokenSignature(t *testing.T) {
+	o := newOAuthTest(t)
+
+	req := o.authorize(t, jwt.MapClaims{"sub": "subject-1", "email": "new@example.com"}, newRSAKey(t))
+
+	_, err := o.callback(req)
+	assertKind(t, err, domainerr.KindUnauthorized)
+	if len(o.users.users) != 0 {
+		t.Error("a user was created from a forged ID token")
+	}
+}
+
+func TestOAuthCallbackRejectsWrongNonce(t *testing.T) {
+	o := newOAuthTest(t)
+
+	req := o.authorize(t, jwt.MapClaims{"sub": "subject-1", "email": "new@example.com", "nonce": "another-login"}, nil)
+
+	_, err := o.callback(req)
+	assertKind(t, err, domainerr.KindUnauthorized)
+	if len(o.users.users) != 0 {
+		t.Error("a user was created from an ID token for another login")
+	}
+}
+
+func TestOAuthCallbackLinksVerifiedEmail(t *testing.T) {
+	o := newOAuthTest(t)
+	existing := &models.User{Username: "existing", Email: "existing@example.com", IsActive: true}
+	o.users.Create(context.Background(), existing)
+
+	req := o.authorize(t, jwt.MapClaims{"sub": "subject-1", "email": "existing@example.com", "email_verified": true}, nil)
+
+	resp, err := o.callback(req)
+	if err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	if resp.User.ID != existing.ID || len(o.users.users) != 1 {
+		t.Errorf("logged in as user %d with %d users, want the existing account", resp.User.ID, len(o.users.users))
+	}
+	assertLinked(t, o.identities, existing.ID, "subject-1")
+}
+
+func TestOAuthCallbackRejectsUnverifiedEmailMatch(t *testing.T) {
+	o := newOAuthTest(t)
+	existing := &models.User{Username: "existing", Email: "existing@example.com", IsActive: true}
+	o.users.Create(context.Background(), existing)
+
+	req := o.authorize(t, jwt.MapClaims{"sub": "subject-1", "email": "existing@example.com", "email_verified": false}, nil)
+
+	_, err := o.callback(req)
+	assertKind(t, err, domainerr.KindConflict)
+	if len(o.identities.identities) != 0 {
+		t.Error("an unverified email was linked to an existing account")
+	}
+}
+
+func TestOAuthCallbackReturnsIdentityLookupErrors(t *testing.T) {
+	o := newOAuthTest(t)
+	lookupErr := errors.New("connection reset")
+	o.identities.err = lookupErr
+
+	req := o.authorize(t, jwt.MapClaims{"sub": "subject-1", "email": "new@example.com", "email_verified": true}, nil)
+
+	_, err := o.callback(req)
+	if !errors.Is(err, lookupErr) {
+		t.Fatalf("err = %v, want %v", err, lookupErr)
+	}
+	if len(o.users.users) != 0 || len(o.identities.identities) != 0 {
+		t.Error("a lookup failure created an account or identity")
+	}
+}
+
+func TestOAuthDiscoveryDoesNotBlockOtherProviders(t *testing.T) {
+	o := newOAuthTest(t)
+	slow := newFakeIssuer(t)
+	slow.stall = make(chan struct{})
+	slow.stalled = make(chan struct{}, 1)
+	// Released before the issuer shuts down even if the test fails early
+	release := sync.OnceFunc(func() { close(slow.stall) })
+	t.Cleanup(release)
+	o.service.config.OAuth.Providers["slow"] = config.OAuthProviderConfig{
+		Name:      "slow",
+		IssuerURL: slow.server.URL,
+		ClientID:  testClientID,
+		Scopes:    []string{"openid"},
+	}
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := o.service.AuthorizationURL(context.Background(), "slow")
+		slowDone <- err
+	}()
+	<-slow.stalled
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := o.service.AuthorizationURL(context.Background(), testProvider)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("AuthorizationURL: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("discovery of one provider blocked another")
+	}
+
+	release()
+	if err := <-slowDone; err != nil {
+		t.Fatalf("AuthorizationURL for the slow provider: %v", err)
+	}
+}
+
+func assertKind(t *testing.T, err error, kind domainerr.Kind) {
+	t.Helper()
+	domainErr, ok := domainerr.As(err)
+	if !ok || domainErr.Kind != kind {
+		t.Fatalf("err = %v, want a %s error", err, kind)
+	}
+}
+
+func assertLinked(t *testing.T, identities *fakeIdentityRepo, userID uint, subject string) {
+	t.Helper()
+	linked, _ := identities.GetByUserID(context.Background(), userID)
+	if len(linked) != 1 || linked[0].Provider != testProvider || linked[0].Subject != subject {
+		t.Errorf("identities for user %d = %+v, want %s/%s", userID, linked, testProvider, subject)
+	}
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	return key
+}
+
+// newTestKeySet writes an Ed25519 signing key to a temporary directory and loads it
+func newTestKeySet(t *testing.T) *utils.KeySet {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, "test.pem"), pemData, 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	keys, err := utils.LoadKeySet(dir, "test")
+	if err != nil {
+		t.Fatalf("load key set: %v", err)
+	}
+	return keys
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
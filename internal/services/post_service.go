@@ -1,177 +1,446 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+
+	"social-media-app/internal/logging"
+	"social-media-app/internal/metrics"
 	"social-media-app/internal/models"
 	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
 )
 
 type PostService struct {
-	postRepo  repository.PostRepository
-	likeRepo  repository.LikeRepository
-	cacheRepo repository.CacheRepository
-	userRepo  repository.UserRepository
+	postRepo    repository.PostRepository
+	likeRepo    repository.LikeRepository
+	cacheRepo   repository.CacheRepository
+	userRepo    repository.UserRepository
+	auditLogger AuditLogger
+	reportRepo  repository.ReportRepository
+	filter      ContentFilter
+
+	// Coalesces concurrent cache misses for the same key into one load
+	loads singleflight.Group
 }
 
-func NewPostService(postRepo repository.PostRepository, likeRepo repository.LikeRepository, cacheRepo repository.CacheRepository, userRepo repository.UserRepository) *PostService {
+func NewPostService(postRepo repository.PostRepository, likeRepo repository.LikeRepository, cacheRepo repository.CacheRepository, userRepo repository.UserRepository, auditLogger AuditLogger, reportRepo repository.ReportRepository, filter ContentFilter) *PostService {
 	return &PostService{
-		postRepo:  postRepo,
-		likeRepo:  likeRepo,
-		cacheRepo: cacheRepo,
-		userRepo:  userRepo,
+		postRepo:    postRepo,
+		likeRepo:    likeRepo,
+		cacheRepo:   cacheRepo,
+		userRepo:    userRepo,
+		auditLogger: auditLogger,
+		reportRepo:  reportRepo,
+		filter:      filter,
 	}
 }
 
-func (s *PostService) CreatePost(userID uint, content, imageURL string) error {
+// CreatePost runs the content filters and stores the post. Flagged posts are
+// stored hidden and queued for moderator review.
+func (s *PostService) CreatePost(ctx context.Context, userID uint, content, imageURL string) (*models.PostResponse, error) {
+	ctx, span := tracer.Start(ctx, "PostService.CreatePost")
+	defer span.End()
+
+	result := s.filter.Check(ctx, ContentToCheck{UserID: userID, Content: content, ImageURL: imageURL})
+	if result.Action == FilterReject {
+		return nil, contentRejectedError(result)
+	}
+
 	post := &models.Post{
 		UserID:   userID,
 		Content:  content,
 		ImageURL: imageURL,
+		IsHidden: result.Action == FilterFlag,
 	}
 
-	err := s.postRepo.Create(post)
+	err := s.postRepo.Create(ctx, post)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Invalidate timeline cache for all followers
-	s.invalidateFollowersTimeline(userID)
+	if post.IsHidden {
+		metrics.PostsCreated.WithLabelValues("hidden").Inc()
+		s.reportFlaggedPost(ctx, post.ID, result.Reasons)
+	} else {
+		metrics.PostsCreated.WithLabelValues("visible").Inc()
 
-	return nil
-}
-
-func (s *PostService) GetByID(postID uint) (*models.PostResponse, error) {
-	// Try to get from cache first
-	if cached, err := s.cacheRepo.GetPostCache(postID); err == nil {
-		return cached, nil
+		// Invalidate timeline cache for all followers
+		s.invalidateFollowersTimeline(ctx, userID)
 	}
 
-	post, err := s.postRepo.GetByID(postID)
+	// Reload to include the author
+	created, err := s.postRepo.GetByID(ctx, post.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	response := post.ToResponse()
-	// Cache the post for 10 minutes
-	s.cacheRepo.SetPostCache(postID, response, 10*time.Minute)
-
+	response := created.ToResponse()
 	return &response, nil
 }
 
-func (s *PostService) GetAll(limit, offset int) ([]models.PostResponse, error) {
-	posts, err := s.postRepo.GetAll(limit, offset)
-	if err != nil {
-		return nil, err
+// GetByID returns a post with IsLiked set for the viewer
+func (s *PostService) GetByID(ctx context.Context, viewerID, postID uint) (*models.PostResponse, error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetByID")
+	defer span.End()
+
+	// Try to get from cache first
+	cached, err := s.cacheRepo.GetPostCache(ctx, postID)
+	metrics.CacheLookup(metrics.CachePost, err == nil)
+	if err == nil {
+		responses := []models.PostResponse{*cached}
+		s.setLiked(ctx, viewerID, responses)
+		return &responses[0], nil
 	}
 
-	var responses []models.PostResponse
-	for _, post := range posts {
-		responses = append(responses, post.ToResponse())
+	loaded, err, _ := s.loads.Do(fmt.Sprintf("post:%d", postID), func() (interface{}, error) {
+		// Shared by every waiting request, so it must outlive this one
+		ctx := context.WithoutCancel(ctx)
+
+		start := time.Now()
+		post, err := s.postRepo.GetByID(ctx, postID)
+		if err != nil {
+			return nil, err
+		}
+
+		response := post.ToResponse()
+		s.cacheRepo.SetPostCache(ctx, postID, response, time.Since(start))
+		return response, nil
+	})
+	if err != nil {
+		return nil, notFound(err, "post not found")
 	}
 
-	return responses, nil
+	responses := []models.PostResponse{loaded.(models.PostResponse)}
+	s.setLiked(ctx, viewerID, responses)
+	return &responses[0], nil
 }
 
-func (s *PostService) GetByUserID(userID uint, limit, offset int) ([]models.PostResponse, error) {
-	posts, err := s.postRepo.GetByUserID(userID, limit, offset)
+// GetAll lists visible posts, newest first, with IsLiked set for the viewer
+func (s *PostService) GetAll(ctx context.Context, viewerID uint, limit, offset int) ([]models.PostResponse, error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetAll")
+	defer span.End()
+
+	posts, err := s.postRepo.GetAll(ctx, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 
-	var responses []models.PostResponse
-	for _, post := range posts {
-		responses = append(responses, post.ToResponse())
+	return s.toResponses(ctx, viewerID, posts), nil
+}
+
+// GetByUserID lists a user's visible posts with IsLiked set for the viewer
+func (s *PostService) GetByUserID(ctx context.Context, viewerID, userID uint, limit, offset int) ([]models.PostResponse, error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetByUserID")
+	defer span.End()
+
+	posts, err := s.postRepo.GetByUserID(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
 	}
 
-	return responses, nil
+	return s.toResponses(ctx, viewerID, posts), nil
 }
 
-func (s *PostService) Update(post *models.Post) error {
-	// Get the original post to get the user ID
-	originalPost, err := s.postRepo.GetByID(post.ID)
+// Update edits the content of one of the user's own posts
+func (s *PostService) Update(ctx context.Context, userID, postID uint, content, imageURL string) (*models.PostResponse, error) {
+	ctx, span := tracer.Start(ctx, "PostService.Update")
+	defer span.End()
+
+	post, err := s.postRepo.GetByID(ctx, postID)
 	if err != nil {
-		return err
+		return nil, notFound(err, "post not found")
+	}
+	if post.UserID != userID {
+		return nil, domainerr.Forbidden("you can only edit your own posts")
+	}
+
+	result := s.filter.Check(ctx, ContentToCheck{UserID: post.UserID, PostID: post.ID, Content: content, ImageURL: imageURL})
+	if result.Action == FilterReject {
+		return nil, contentRejectedError(result)
+	}
+
+	if result.Action == FilterFlag && !post.IsHidden {
+		if err := s.postRepo.SetHidden(ctx, post.ID, true); err != nil {
+			return nil, err
+		}
+		post.IsHidden = true
+		s.reportFlaggedPost(ctx, post.ID, result.Reasons)
+	}
+
+	post.Content = content
+	post.ImageURL = imageURL
+
+	if err := s.postRepo.Update(ctx, post); err != nil {
+		return nil, err
 	}
 
-	// Preserve the user ID from the original post
-	post.UserID = originalPost.UserID
+	s.invalidatePost(ctx, post)
 
-	// Invalidate post cache
-	s.cacheRepo.DeletePostCache(post.ID)
+	responses := []models.PostResponse{post.ToResponse()}
+	s.setLiked(ctx, userID, responses)
+	return &responses[0], nil
+}
 
-	// Invalidate timeline cache for all followers
-	s.invalidateFollowersTimeline(post.UserID)
+// DeleteOwnPost deletes a post on behalf of its author
+func (s *PostService) DeleteOwnPost(ctx context.Context, meta models.RequestMeta, postID uint) error {
+	ctx, span := tracer.Start(ctx, "PostService.DeleteOwnPost")
+	defer span.End()
+
+	post, err := s.postRepo.GetByID(ctx, postID)
+	if err != nil {
+		return notFound(err, "post not found")
+	}
+	if post.UserID != meta.ActorID {
+		return domainerr.Forbidden("you can only delete your own posts")
+	}
 
-	return s.postRepo.Update(post)
+	return s.Delete(ctx, meta, postID)
 }
 
-func (s *PostService) Delete(postID uint) error {
+// Delete removes any post regardless of ownership
+func (s *PostService) Delete(ctx context.Context, meta models.RequestMeta, postID uint) error {
+	ctx, span := tracer.Start(ctx, "PostService.Delete")
+	defer span.End()
+
 	// Get post to find user ID before deletion
-	post, err := s.postRepo.GetByID(postID)
+	post, err := s.postRepo.GetByID(ctx, postID)
 	if err != nil {
+		return notFound(err, "post not found")
+	}
+
+	if err := s.postRepo.Delete(ctx, postID); err != nil {
 		return err
 	}
 
-	// Invalidate post cache
-	s.cacheRepo.DeletePostCache(postID)
+	s.invalidatePost(ctx, post)
+
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionDeletePost,
+		TargetType: AuditTargetPost,
+		TargetID:   postID,
+		Changes: map[string]models.AuditChange{
+			"author_id": {From: post.UserID, To: nil},
+			"content":   {From: post.Content, To: nil},
+		},
+	})
+	return nil
+}
 
-	// Invalidate timeline cache for all followers
-	s.invalidateFollowersTimeline(post.UserID)
+// Number of post IDs cached per timeline. Pages beyond them are read from
+// the database.
+const timelineCacheSize = 100
 
-	return s.postRepo.Delete(postID)
-}
+// GetTimeline returns posts by the user and everyone they follow, newest
+// first, with IsLiked set for the user
+func (s *PostService) GetTimeline(ctx context.Context, userID uint, limit, offset int) ([]models.PostResponse, error) {
+	ctx, span := tracer.Start(ctx, "PostService.GetTimeline")
+	defer span.End()
 
-func (s *PostService) GetTimeline(userID uint, limit, offset int) ([]models.PostResponse, error) {
 	// Try to get from cache first
-	if cached, err := s.cacheRepo.GetTimeline(userID); err == nil && len(cached) > 0 {
-		start := offset
-		end := offset + limit
-		if start >= len(cached) {
-			return []models.PostResponse{}, nil
+	postIDs, err := s.cacheRepo.GetTimeline(ctx, userID)
+	metrics.CacheLookup(metrics.CacheTimeline, err == nil)
+	if err != nil {
+		loaded, err, _ := s.loads.Do(fmt.Sprintf("timeline:%d", userID), func() (interface{}, error) {
+			ctx := context.WithoutCancel(ctx)
+
+			start := time.Now()
+			postIDs, err := s.postRepo.GetTimelineIDs(ctx, userID, timelineCacheSize)
+			if err != nil {
+				return nil, err
+			}
+			s.cacheRepo.SetTimeline(ctx, userID, postIDs, time.Since(start))
+			return postIDs, nil
+		})
+		if err != nil {
+			return nil, err
 		}
-		if end > len(cached) {
-			end = len(cached)
+		postIDs = loaded.([]uint)
+	}
+
+	if offset+limit > len(postIDs) && len(postIDs) == timelineCacheSize {
+		posts, err := s.postRepo.GetTimeline(ctx, userID, limit, offset)
+		if err != nil {
+			return nil, err
 		}
-		return cached[start:end], nil
+		return s.toResponses(ctx, userID, posts), nil
+	}
+
+	if offset >= len(postIDs) {
+		return []models.PostResponse{}, nil
 	}
+	end := min(offset+limit, len(postIDs))
 
-	posts, err := s.postRepo.GetTimeline(userID, limit, offset)
+	responses, err := s.loadPosts(ctx, postIDs[offset:end])
 	if err != nil {
 		return nil, err
 	}
+	s.setLiked(ctx, userID, responses)
 
-	var responses []models.PostResponse
-	for _, post := range posts {
-		response := post.ToResponse()
+	return responses, nil
+}
 
-		isLiked, _ := s.likeRepo.Exists(userID, post.ID)
-		response.IsLiked = isLiked
+// SetHidden hides a post from listings pending moderation, or restores it
+func (s *PostService) SetHidden(ctx context.Context, postID uint, hidden bool) error {
+	ctx, span := tracer.Start(ctx, "PostService.SetHidden")
+	defer span.End()
 
-		responses = append(responses, response)
+	post, err := s.postRepo.GetByID(ctx, postID)
+	if err != nil {
+		return notFound(err, "post not found")
 	}
 
-	// Cache the timeline for 5 minutes
-	if len(responses) > 0 {
-		s.cacheRepo.SetTimeline(userID, responses, 5*time.Minute)
+	if err := s.postRepo.SetHidden(ctx, postID, hidden); err != nil {
+		return err
 	}
 
+	// Cached timelines may still contain the post
+	s.invalidatePost(ctx, post)
+
+	return nil
+}
+
+// loadPosts returns the posts in the order of postIDs, reading through the
+// post cache in one round trip and loading any misses in one query. Posts
+// deleted or hidden since their IDs were cached are left out.
+func (s *PostService) loadPosts(ctx context.Context, postIDs []uint) ([]models.PostResponse, error) {
+	cached, err := s.cacheRepo.GetPostsCache(ctx, postIDs)
+	if err != nil {
+		cached = make(map[uint]models.PostResponse, len(postIDs))
+	}
+
+	var missing []uint
+	for _, postID := range postIDs {
+		_, ok := cached[postID]
+		metrics.CacheLookup(metrics.CachePost, ok)
+		if !ok {
+			missing = append(missing, postID)
+		}
+	}
+
+	if len(missing) > 0 {
+		loaded, err, _ := s.loads.Do(fmt.Sprintf("posts:%v", missing), func() (interface{}, error) {
+			ctx := context.WithoutCancel(ctx)
+
+			start := time.Now()
+			posts, err := s.postRepo.GetByIDs(ctx, missing)
+			if err != nil {
+				return nil, err
+			}
+
+			responses := make([]models.PostResponse, 0, len(posts))
+			for _, post := range posts {
+				responses = append(responses, post.ToResponse())
+			}
+			s.cacheRepo.SetPostsCache(ctx, responses, time.Since(start))
+			return responses, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, post := range loaded.([]models.PostResponse) {
+			cached[post.ID] = post
+		}
+	}
+
+	responses := make([]models.PostResponse, 0, len(postIDs))
+	for _, postID := range postIDs {
+		if post, ok := cached[postID]; ok && !post.IsHidden {
+			responses = append(responses, post)
+		}
+	}
 	return responses, nil
 }
 
-// Helper function to invalidate timeline cache for all followers of a user
-func (s *PostService) invalidateFollowersTimeline(userID uint) {
-	// Invalidate the user's own timeline
-	s.cacheRepo.DeleteTimeline(userID)
+// toResponses converts posts for the viewer, looking up which of them the
+// viewer has liked in one query
+func (s *PostService) toResponses(ctx context.Context, viewerID uint, posts []models.Post) []models.PostResponse {
+	var responses []models.PostResponse
+	for _, post := range posts {
+		responses = append(responses, post.ToResponse())
+	}
+	s.setLiked(ctx, viewerID, responses)
+	return responses
+}
 
-	// Get all followers of the user
-	followers, err := s.userRepo.GetFollowers(userID)
+// setLiked sets IsLiked on each post the viewer has liked. A failed lookup
+// is logged and leaves the posts unliked rather than failing the listing.
+func (s *PostService) setLiked(ctx context.Context, viewerID uint, posts []models.PostResponse) {
+	if viewerID == 0 || len(posts) == 0 {
+		return
+	}
+
+	postIDs := make([]uint, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+
+	liked, err := s.likeRepo.LikedPostIDs(ctx, viewerID, postIDs)
 	if err != nil {
+		logging.FromContext(ctx).Error("Failed to look up liked posts", "error", err)
 		return
 	}
 
-	// Invalidate timeline cache for each follower
-	for _, follower := range followers {
-		s.cacheRepo.DeleteTimeline(follower.ID)
+	likedSet := make(map[uint]bool, len(liked))
+	for _, postID := range liked {
+		likedSet[postID] = true
+	}
+	for i := range posts {
+		posts[i].IsLiked = likedSet[posts[i].ID]
+	}
+}
+
+// reportFlaggedPost files a report on behalf of the content filters so the
+// post shows up in the moderation queue
+func (s *PostService) reportFlaggedPost(ctx context.Context, postID uint, reasons []string) {
+	report := &models.Report{
+		ReporterID: 0, // filed automatically
+		TargetType: models.ReportTargetPost,
+		TargetID:   postID,
+		Reason:     models.ReportReasonSpam,
+		Details:    "Flagged by content filter: " + strings.Join(reasons, "; "),
+		Status:     models.ReportStatusOpen,
+	}
+	if err := s.reportRepo.Create(ctx, report); err != nil {
+		logging.FromContext(ctx).Error("Failed to report flagged post", "post_id", postID, "error", err)
+	}
+}
+
+// invalidatePost drops the cached post and the timelines it may be on. Call
+// it after the database write: invalidating first leaves a gap in which a
+// concurrent read reloads and caches the old row.
+func (s *PostService) invalidatePost(ctx context.Context, post *models.Post) {
+	s.cacheRepo.DeletePostCache(context.WithoutCancel(ctx), post.ID)
+	s.invalidateFollowersTimeline(ctx, post.UserID)
+}
+
+// Number of follower IDs read at a time when invalidating their timelines
+const followerBatchSize = 500
+
+// Helper function to invalidate timeline cache for all followers of a user
+func (s *PostService) invalidateFollowersTimeline(ctx context.Context, userID uint) {
+	// The post has already changed, so stale timelines must be cleared even
+	// if the client has gone away
+	ctx = context.WithoutCancel(ctx)
+
+	// Invalidate the user's own timeline
+	s.cacheRepo.DeleteTimeline(ctx, userID)
+
+	// Invalidate timeline cache for each follower, a batch at a time
+	var afterID uint
+	for {
+		followerIDs, err := s.userRepo.GetFollowerIDs(ctx, userID, afterID, followerBatchSize)
+		if err != nil || len(followerIDs) == 0 {
+			return
+		}
+		for _, followerID := range followerIDs {
+			s.cacheRepo.DeleteTimeline(ctx, followerID)
+		}
+		afterID = followerIDs[len(followerIDs)-1]
 	}
 }
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"social-media-app/internal/config"
+	"social-media-app/internal/logging"
+	"social-media-app/internal/models"
+	"social-media-app/internal/repository"
+	"social-media-app/internal/services/domainerr"
+)
+
+type ReportService struct {
+	reportRepo  repository.ReportRepository
+	postRepo    repository.PostRepository
+	userRepo    repository.UserRepository
+	postService *PostService
+	auditLogger AuditLogger
+	config      *config.Config
+}
+
+func NewReportService(reportRepo repository.ReportRepository, postRepo repository.PostRepository, userRepo repository.UserRepository, postService *PostService, auditLogger AuditLogger, cfg *config.Config) *ReportService {
+	return &ReportService{
+		reportRepo:  reportRepo,
+		postRepo:    postRepo,
+		userRepo:    userRepo,
+		postService: postService,
+		auditLogger: auditLogger,
+		config:      cfg,
+	}
+}
+
+// CreateReport files a report. Once enough distinct users report a post it
+// is hidden from listings until a moderator reviews it.
+func (s *ReportService) CreateReport(ctx context.Context, meta models.RequestMeta, reporterID uint, req models.CreateReportRequest) (*models.Report, error) {
+	ctx, span := tracer.Start(ctx, "ReportService.CreateReport")
+	defer span.End()
+
+	switch req.TargetType {
+	case models.ReportTargetPost:
+		post, err := s.postRepo.GetByID(ctx, req.TargetID)
+		if err != nil {
+			return nil, notFound(err, "post not found")
+		}
+		if post.UserID == reporterID {
+			return nil, domainerr.Validation("cannot report your own post")
+		}
+	case models.ReportTargetUser:
+		if req.TargetID == reporterID {
+			return nil, domainerr.Validation("cannot report yourself")
+		}
+		if _, err := s.userRepo.GetByID(ctx, req.TargetID); err != nil {
+			return nil, notFound(err, "user not found")
+		}
+	default:
+		return nil, domainerr.Validation("unsupported report target")
+	}
+
+	exists, err := s.reportRepo.ExistsOpen(ctx, reporterID, req.TargetType, req.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, domainerr.Conflict("you have already reported this")
+	}
+
+	report := &models.Report{
+		ReporterID: reporterID,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+		Status:     models.ReportStatusOpen,
+	}
+	if err := s.reportRepo.Create(ctx, report); err != nil {
+		return nil, err
+	}
+
+	if req.TargetType == models.ReportTargetPost {
+		s.hideIfOverThreshold(ctx, meta, req.TargetID)
+	}
+
+	return report, nil
+}
+
+func (s *ReportService) hideIfOverThreshold(ctx context.Context, meta models.RequestMeta, postID uint) {
+	reporters, err := s.reportRepo.CountOpenReporters(ctx, models.ReportTargetPost, postID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to count reports", "post_id", postID, "error", err)
+		return
+	}
+	if reporters < int64(s.config.Moderation.ReportHideThreshold) {
+		return
+	}
+
+	if err := s.postService.SetHidden(ctx, postID, true); err != nil {
+		logging.FromContext(ctx).Error("Failed to hide reported post", "post_id", postID, "error", err)
+		return
+	}
+
+	// Hidden automatically, so there is no actor
+	meta.ActorID = 0
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionHidePost,
+		TargetType: AuditTargetPost,
+		TargetID:   postID,
+		Changes: map[string]models.AuditChange{
+			"is_hidden": {From: false, To: true},
+			"reports":   {To: reporters},
+		},
+	})
+}
+
+// ListReports returns the moderation queue
+func (s *ReportService) ListReports(ctx context.Context, filter models.ReportFilter, limit, offset int) ([]models.Report, error) {
+	ctx, span := tracer.Start(ctx, "ReportService.ListReports")
+	defer span.End()
+
+	return s.reportRepo.List(ctx, filter, limit, offset)
+}
+
+// AssignReport assigns an open report to a moderator
+func (s *ReportService) AssignReport(ctx context.Context, meta models.RequestMeta, reportID, assigneeID uint) (*models.Report, error) {
+	ctx, span := tracer.Start(ctx, "ReportService.AssignReport")
+	defer span.End()
+
+	report, err := s.getOpenReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	if assigneeID == 0 {
+		assigneeID = meta.ActorID
+	}
+	assignee, err := s.userRepo.GetByID(ctx, assigneeID)
+	if err != nil {
+		return nil, notFound(err, "assignee not found")
+	}
+	if !assignee.HasPermission(models.PermissionModerate) {
+		return nil, domainerr.Validation("assignee is not a moderator")
+	}
+
+	previous := report.AssigneeID
+	report.AssigneeID = &assignee.ID
+	if err := s.reportRepo.Update(ctx, report); err != nil {
+		return nil, err
+	}
+
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionAssignReport,
+		TargetType: AuditTargetReport,
+		TargetID:   report.ID,
+		Changes: map[string]models.AuditChange{
+			"assignee_id": {From: previous, To: assignee.ID},
+		},
+	})
+
+	return report, nil
+}
+
+// ResolveReport upholds a report, closing every open report on the same
+// target. The reported post is deleted if requested, otherwise a hidden
+// post stays hidden.
+func (s *ReportService) ResolveReport(ctx context.Context, meta models.RequestMeta, reportID uint, req models.ResolveReportRequest) (*models.Report, error) {
+	ctx, span := tracer.Start(ctx, "ReportService.ResolveReport")
+	defer span.End()
+
+	report, err := s.getOpenReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RemoveContent {
+		if report.TargetType != models.ReportTargetPost {
+			return nil, domainerr.Validation("only reported posts can be removed")
+		}
+		if err := s.postService.Delete(ctx, meta, report.TargetID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.closeReports(ctx, report, models.ReportStatusResolved, meta.ActorID, req.Note); err != nil {
+		return nil, err
+	}
+
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionResolveReport,
+		TargetType: AuditTargetReport,
+		TargetID:   report.ID,
+		Changes: map[string]models.AuditChange{
+			"status":         {From: models.ReportStatusOpen, To: models.ReportStatusResolved},
+			"remove_content": {To: req.RemoveContent},
+		},
+	})
+
+	return report, nil
+}
+
+// DismissReport rejects a report, closing every open report on the same
+// target and restoring the post if it was hidden
+func (s *ReportService) DismissReport(ctx context.Context, meta models.RequestMeta, reportID uint, req models.DismissReportRequest) (*models.Report, error) {
+	ctx, span := tracer.Start(ctx, "ReportService.DismissReport")
+	defer span.End()
+
+	report, err := s.getOpenReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.closeReports(ctx, report, models.ReportStatusDismissed, meta.ActorID, req.Note); err != nil {
+		return nil, err
+	}
+
+	if report.TargetType == models.ReportTargetPost {
+		if err := s.postService.SetHidden(ctx, report.TargetID, false); err != nil {
+			logging.FromContext(ctx).Error("Failed to restore post", "post_id", report.TargetID, "error", err)
+		}
+	}
+
+	s.auditLogger.Log(ctx, meta, AuditEvent{
+		Action:     AuditActionDismissReport,
+		TargetType: AuditTargetReport,
+		TargetID:   report.ID,
+		Changes: map[string]models.AuditChange{
+			"status": {From: models.ReportStatusOpen, To: models.ReportStatusDismissed},
+		},
+	})
+
+	return report, nil
+}
+
+func (s *ReportService) getOpenReport(ctx context.Context, reportID uint) (*models.Report, error) {
+	report, err := s.reportRepo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, notFound(err, "report not found")
+	}
+	if report.Status != models.ReportStatusOpen {
+		return nil, domainerr.Conflict("report is already closed")
+	}
+	return report, nil
+}
+
+func (s *ReportService) closeReports(ctx context.Context, report *models.Report, status models.ReportStatus, moderatorID uint, note string) error {
+	if err := s.reportRepo.CloseOpenForTarget(ctx, report.TargetType, report.TargetID, status, moderatorID, note); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	report.Status = status
+	report.ResolvedByID = &moderatorID
+	report.ResolutionNote = note
+	report.ResolvedAt = &now
+	return nil
+}
@@ -0,0 +1,6 @@
+package services
+
+import "go.opentelemetry.io/otel"
+
+// tracer records a span for each service call
+var tracer = otel.Tracer("social-media-app/internal/services")
@@ -1,78 +1,128 @@
 package services
 
 import (
+	"context"
+
 	"social-media-app/internal/models"
 	"social-media-app/internal/repository"
 	"social-media-app/internal/utils"
 )
 
 type UserService struct {
-	userRepo repository.UserRepository
+	userRepo    repository.UserRepository
+	followRepo  repository.FollowRepository
+	auditLogger AuditLogger
 }
 
-func NewUserService(userRepo repository.UserRepository) *UserService {
+func NewUserService(userRepo repository.UserRepository, followRepo repository.FollowRepository, auditLogger AuditLogger) *UserService {
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		followRepo:  followRepo,
+		auditLogger: auditLogger,
 	}
 }
 
-func (s *UserService) GetProfile(userID uint) (*models.UserResponse, error) {
-	user, err := s.userRepo.GetByID(userID)
+func (s *UserService) GetProfile(ctx context.Context, userID uint) (*models.ProfileResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetProfile")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "user not found")
 	}
 
-	response := user.ToResponse()
+	response := user.ToProfileResponse()
 	return &response, nil
 }
 
-func (s *UserService) GetUserByID(userID uint) (*models.UserResponse, error) {
-	user, err := s.userRepo.GetByID(userID)
+// GetUserByID returns the user's public profile, including whether the
+// viewer and the user follow each other
+func (s *UserService) GetUserByID(ctx context.Context, viewerID, userID uint) (*models.ProfileResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByID")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "user not found")
 	}
 
-	response := user.ToResponse()
+	response := user.ToProfileResponse()
+	if viewerID != userID {
+		if response.YouFollow, err = s.followRepo.Exists(ctx, viewerID, userID); err != nil {
+			return nil, err
+		}
+		if response.FollowsYou, err = s.followRepo.Exists(ctx, userID, viewerID); err != nil {
+			return nil, err
+		}
+	}
 	return &response, nil
 }
 
-func (s *UserService) UpdateProfile(userID uint, req models.UpdateProfileRequest) (*models.UserResponse, error) {
-	user, err := s.userRepo.GetByID(userID)
+func (s *UserService) UpdateProfile(ctx context.Context, meta models.RequestMeta, userID uint, req models.UpdateProfileRequest) (*models.UserResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateProfile")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "user not found")
 	}
 
-	if req.FirstName != "" {
-		user.FirstName = req.FirstName
-	}
-	if req.LastName != "" {
-		user.LastName = req.LastName
-	}
-	if req.Bio != "" {
-		user.Bio = req.Bio
-	}
-	if req.Avatar != "" {
-		user.Avatar = req.Avatar
+	changes := make(map[string]models.AuditChange)
+	setField := func(name string, field *string, value string) {
+		if value != "" && value != *field {
+			changes[name] = models.AuditChange{From: *field, To: value}
+			*field = value
+		}
 	}
+
+	setField("first_name", &user.FirstName, req.FirstName)
+	setField("last_name", &user.LastName, req.LastName)
+	setField("bio", &user.Bio, req.Bio)
+	setField("avatar", &user.Avatar, req.Avatar)
+
+	passwordChanged := false
 	if req.Password != "" {
 		hashedPassword, err := utils.HashPassword(req.Password)
 		if err != nil {
 			return nil, err
 		}
 		user.Password = hashedPassword
+		passwordChanged = true
 	}
 
-	if err := s.userRepo.Update(user); err != nil {
+	if err := s.userRepo.Update(ctx, user); err != nil {
 		return nil, err
 	}
 
+	if len(changes) > 0 {
+		s.auditLogger.Log(ctx, meta, AuditEvent{
+			Action:     AuditActionProfileUpdate,
+			TargetType: AuditTargetUser,
+			TargetID:   user.ID,
+			Changes:    changes,
+		})
+	}
+	if passwordChanged {
+		s.auditLogger.Log(ctx, meta, AuditEvent{
+			Action:     AuditActionPasswordChange,
+			TargetType: AuditTargetUser,
+			TargetID:   user.ID,
+			Changes: map[string]models.AuditChange{
+				"password": {From: redactedValue, To: redactedValue},
+			},
+		})
+	}
+
 	response := user.ToResponse()
 	return &response, nil
 }
 
 // SearchUsers searches for users by name or username
-func (s *UserService) SearchUsers(query string, limit, offset int) ([]models.UserResponse, error) {
-	users, err := s.userRepo.SearchUsers(query, limit, offset)
+func (s *UserService) SearchUsers(ctx context.Context, query string, limit, offset int) ([]models.UserResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserService.SearchUsers")
+	defer span.End()
+
+	users, err := s.userRepo.SearchUsers(ctx, query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
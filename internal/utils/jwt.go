@@ -7,18 +7,33 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenPurposeTwoFactor marks a token that only allows completing a 2FA login
+const TokenPurposeTwoFactor = "2fa_challenge"
+
 type JWTClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Purpose  string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, username, email, secret string, expiry time.Duration) (string, error) {
+func GenerateToken(userID uint, username, email string, keys *KeySet, expiry time.Duration) (string, error) {
+	return generateToken(userID, username, email, "", keys, expiry)
+}
+
+// GenerateChallengeToken issues a short-lived token that can only be exchanged
+// for a full token by passing the second authentication factor
+func GenerateChallengeToken(userID uint, keys *KeySet, expiry time.Duration) (string, error) {
+	return generateToken(userID, "", "", TokenPurposeTwoFactor, keys, expiry)
+}
+
+func generateToken(userID uint, username, email, purpose string, keys *KeySet, expiry time.Duration) (string, error) {
 	claims := JWTClaims{
 		UserID:   userID,
 		Username: username,
 		Email:    email,
+		Purpose:  purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -26,17 +41,35 @@ func GenerateToken(userID uint, username, email, secret string, expiry time.Dura
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keys.Sign(claims)
+}
+
+// ValidateToken validates an access token. Challenge tokens are rejected.
+func ValidateToken(tokenString string, keys *KeySet) (*JWTClaims, error) {
+	claims, err := parseToken(tokenString, keys)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+func ValidateChallengeToken(tokenString string, keys *KeySet) (*JWTClaims, error) {
+	claims, err := parseToken(tokenString, keys)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != TokenPurposeTwoFactor {
+		return nil, errors.New("invalid challenge token")
+	}
+	return claims, nil
 }
 
-func ValidateToken(tokenString, secret string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
+func parseToken(tokenString string, keys *KeySet) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, keys.Keyfunc,
+		jwt.WithValidMethods(keys.validMethods()))
 
 	if err != nil {
 		return nil, err
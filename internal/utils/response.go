@@ -4,13 +4,41 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+
+	"social-media-app/internal/logging"
+	"social-media-app/internal/services/domainerr"
+)
+
+// Machine-readable error codes returned in APIResponse.Code
+const (
+	ErrCodeInvalidRequest   = "invalid_request"
+	ErrCodeValidationFailed = "validation_failed"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeConflict         = "conflict"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeAccountLocked    = "account_locked"
+	ErrCodeUpstreamError    = "upstream_error"
+	ErrCodeUnavailable      = "service_unavailable"
+	ErrCodeInternalError    = "internal_error"
 )
 
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Data    interface{}  `json:"data,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Code    string       `json:"code,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 func SuccessResponse(c *gin.Context, statusCode int, message string, data interface{}) {
@@ -21,32 +49,93 @@ func SuccessResponse(c *gin.Context, statusCode int, message string, data interf
 	})
 }
 
+// ErrorResponse sends an error with a code derived from the status
 func ErrorResponse(c *gin.Context, statusCode int, message string) {
+	ErrorResponseWithCode(c, statusCode, errorCodeForStatus(statusCode), message)
+}
+
+func ErrorResponseWithCode(c *gin.Context, statusCode int, code, message string) {
 	c.JSON(statusCode, APIResponse{
 		Success: false,
 		Message: message,
 		Error:   message,
+		Code:    code,
 	})
 }
 
-func ValidationErrorResponse(c *gin.Context, errors []string) {
-	c.JSON(http.StatusBadRequest, APIResponse{
+// ValidationErrorResponse reports the fields that failed validation
+func ValidationErrorResponse(c *gin.Context, statusCode int, message string, errors []FieldError) {
+	c.JSON(statusCode, APIResponse{
 		Success: false,
-		Message: "Validation failed",
-		Error:   "Validation errors: " + joinErrors(errors),
+		Message: message,
+		Error:   message,
+		Code:    ErrCodeValidationFailed,
+		Errors:  errors,
 	})
 }
 
-func joinErrors(errors []string) string {
-	if len(errors) == 0 {
-		return ""
+func errorCodeForStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return ErrCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusUnprocessableEntity:
+		return ErrCodeValidationFailed
+	case http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case http.StatusBadGateway:
+		return ErrCodeUpstreamError
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return ErrCodeInternalError
+	}
+	return ErrCodeInvalidRequest
+}
+
+// ServiceErrorResponse maps errors returned by services to HTTP responses.
+// Domain errors get their matching status code; anything else is logged and
+// reported as a generic 500 so internal details are not leaked.
+func ServiceErrorResponse(c *gin.Context, err error) {
+	domainErr, ok := domainerr.As(err)
+	if !ok {
+		logging.FromContext(c.Request.Context()).Error("Internal error", "error", err)
+		trace.SpanFromContext(c.Request.Context()).RecordError(err)
+		ErrorResponse(c, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch domainErr.Kind {
+	case domainerr.KindNotFound:
+		status = http.StatusNotFound
+	case domainerr.KindConflict:
+		status = http.StatusConflict
+	case domainerr.KindForbidden:
+		status = http.StatusForbidden
+	case domainerr.KindValidation:
+		status = http.StatusUnprocessableEntity
+	case domainerr.KindUnauthorized:
+		status = http.StatusUnauthorized
 	}
 
-	result := errors[0]
-	for i := 1; i < len(errors); i++ {
-		result += ", " + errors[i]
+	if len(domainErr.Violations) > 0 {
+		fieldErrors := make([]FieldError, 0, len(domainErr.Violations))
+		for _, violation := range domainErr.Violations {
+			fieldErrors = append(fieldErrors, FieldError(violation))
+		}
+		ValidationErrorResponse(c, status, domainErr.Message, fieldErrors)
+		return
 	}
-	return result
+	ErrorResponse(c, status, domainErr.Message)
 }
 
 type PaginationResponse struct {
@@ -1,49 +1,90 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"social-media-app/internal/api"
 	"social-media-app/internal/api/handlers"
+	"social-media-app/internal/api/middleware"
 	"social-media-app/internal/config"
 	"social-media-app/internal/database"
+	"social-media-app/internal/health"
+	"social-media-app/internal/logging"
 	"social-media-app/internal/repository"
 	"social-media-app/internal/services"
+	"social-media-app/internal/tracing"
+	"social-media-app/internal/utils"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
+	// load config: defaults, then the config file, environment and flags
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	fs.Parse(os.Args[1:])
+
+	cfg, err := config.Load(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logger := logging.New(cfg.Log)
+	if cfg.DotEnvPath != "" {
+		logger.Info("Loaded .env file", "path", cfg.DotEnvPath)
+	}
+
 	cwd, err := os.Getwd()
 	if err != nil {
-		log.Println("Error getting current working directory:", err)
+		logger.Warn("Error getting current working directory", "error", err)
 	} else {
-		log.Println("Current working directory:", cwd)
+		logger.Debug("Current working directory", "cwd", cwd)
+	}
 
-		envPath := filepath.Join(cwd, ".env")
-		if _, err := os.Stat(envPath); err == nil {
-			log.Println(".env file found at:", envPath)
-		} else {
-			log.Println(".env file not found at:", envPath, "Error:", err)
-		}
+	// Set up tracing before anything that records spans
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Warn("Failed to flush traces", "error", err)
+		}
+	}()
 
-	// load config
-	cfg := config.Load()
+	// Load token signing keys, refusing to start without them
+	jwtKeys, err := utils.LoadKeySet(cfg.JWT.KeysDir, cfg.JWT.ActiveKeyID)
+	if err != nil {
+		logger.Error("Failed to load JWT keys", "error", err)
+		os.Exit(1)
+	}
 
 	// Connect to database first
 	if err := database.Connect(cfg); err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logger.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
 	}
 
 	// Run migrations
 	if err := database.Migrate(); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+		logger.Error("Failed to run migrations", "error", err)
+		os.Exit(1)
 	}
 
 	// Add custom constraints
 	if err := database.AddConstraints(); err != nil {
-		log.Printf("Warning: Failed to add constraints: %v", err)
+		logger.Warn("Failed to add constraints", "error", err)
 	}
 
 	// Get the database instance after connection
@@ -55,13 +96,34 @@ func main() {
 	likeRepo := repository.NewLikeRepository(db)
 	followRepo := repository.NewFollowRepository(db)
 	cacheRepo := repository.NewCacheRepository(cfg)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(cfg)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	externalIdentityRepo := repository.NewExternalIdentityRepository(db)
+	oauthStateRepo := repository.NewOAuthStateRepository(cfg)
+	auditLogRepo := repository.NewAuditLogRepository(db)
+	reportRepo := repository.NewReportRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(cfg)
+
+	// Rate limiter, shared by the routes and the admin API. The policy file is
+	// watched until shutdown.
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	rateLimiter, err := middleware.NewRateLimiter(watchCtx, cfg)
+	if err != nil {
+		logger.Error("Failed to set up rate limiting", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize services
-	postService := services.NewPostService(postRepo, likeRepo, cacheRepo, userRepo)
-	likeService := services.NewLikeService(likeRepo, postRepo)
-	followService := services.NewFollowService(followRepo, userRepo, cacheRepo)
-	userService := services.NewUserService(userRepo)
-	authService := services.NewAuthService(userRepo, cfg)
+	auditLogger := services.NewAuditLogger(auditLogRepo)
+	postService := services.NewPostService(postRepo, likeRepo, cacheRepo, userRepo, auditLogger, reportRepo, services.NewContentFilterChain(postRepo, cfg))
+	likeService := services.NewLikeService(likeRepo, postRepo, cacheRepo)
+	followService := services.NewFollowService(followRepo, userRepo, cacheRepo, auditLogger)
+	userService := services.NewUserService(userRepo, followRepo, auditLogger)
+	authService := services.NewAuthService(userRepo, loginAttemptRepo, recoveryCodeRepo, services.NewLogNotifier(), auditLogger, jwtKeys, cfg)
+	oauthService := services.NewOAuthService(authService, userRepo, externalIdentityRepo, oauthStateRepo, cfg)
+	adminService := services.NewAdminService(userRepo, auditLogRepo, auditLogger, postService, rateLimiter)
+	reportService := services.NewReportService(reportRepo, postRepo, userRepo, postService, auditLogger, cfg)
 
 	// Initialize handlers
 	handlers.InitPostHandler(postService)
@@ -69,13 +131,59 @@ func main() {
 	handlers.InitFollowHandler(followService)
 	handlers.InitUserHandler(userService)
 	handlers.InitAuthHandler(authService)
+	handlers.InitOAuthHandler(oauthService)
+	handlers.InitAdminHandler(adminService)
+	handlers.InitReportHandler(reportService)
+
+	// Dependencies checked by /readyz
+	checker := health.NewChecker(2 * time.Second)
+	checker.Register("database", database.Ping)
+	checker.Register("migrations", database.CheckMigrations)
+	if len(cfg.Database.Replicas) > 0 {
+		checker.Register("database_replicas", database.PingReplicas)
+	}
+	checker.Register("redis_cache", cacheRepo.Ping)
+	checker.Register("redis_rate_limit", rateLimiter.Ping)
+	handlers.InitHealthHandler(checker)
 
 	// setup routes
-	router := api.SetupRoutes(cfg)
+	router := api.SetupRoutes(cfg, logger, jwtKeys, userRepo, idempotencyRepo, rateLimiter)
+
+	server := &http.Server{
+		Addr:    cfg.Server.Host + ":" + cfg.Server.Port,
+		Handler: router,
+	}
 
 	// start server
-	log.Printf("Server starting on %s:%s", cfg.Server.Host, cfg.Server.Port)
-	if err := router.Run(cfg.Server.Host + ":" + cfg.Server.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server starting", "host", cfg.Server.Host, "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		logger.Error("Failed to start server", "error", err)
+		os.Exit(1)
+	case sig := <-stop:
+		logger.Info("Shutdown signal received", "signal", sig.String())
+	}
+
+	// Fail readiness first so load balancers stop routing new requests here
+	checker.StartDraining()
+	stopWatching()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("Server did not shut down cleanly", "error", err)
+		return
 	}
+	logger.Info("Server stopped")
 }
@@ -19,12 +19,34 @@ if (loginForm) {
                 body: JSON.stringify({ email, password })
             });
             
-            const data = await response.json();
-            
+            let data = await response.json();
+
             if (!response.ok) {
                 throw new Error(data.error || 'Login failed');
             }
-            
+
+            // Complete the login with a second factor if the account requires it
+            if (data.data.requires_two_factor) {
+                const code = window.prompt('Enter the code from your authenticator app or a recovery code');
+                if (!code) {
+                    throw new Error('Two-factor authentication code required');
+                }
+
+                const verifyResponse = await fetch(`${API_URL}/auth/2fa/verify`, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify({ challenge_token: data.data.challenge_token, code })
+                });
+
+                data = await verifyResponse.json();
+
+                if (!verifyResponse.ok) {
+                    throw new Error(data.error || 'Two-factor verification failed');
+                }
+            }
+
             // Save token and user data
             localStorage.setItem('token', data.data.token);
             localStorage.setItem('user', JSON.stringify(data.data.user));
@@ -67,7 +89,8 @@ if (signupForm) {
             const data = await response.json();
             
             if (!response.ok) {
-                throw new Error(data.error || 'Registration failed');
+                const fieldErrors = (data.errors || []).map(e => e.message).join(', ');
+                throw new Error(fieldErrors || data.error || 'Registration failed');
             }
             
             // Save token and user data
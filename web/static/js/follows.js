@@ -17,8 +17,8 @@ async function checkFollowStatus(profileUserId) {
         
         if (!token || !currentUser) return false;
         
-        // Get followers of the profile user
-        const response = await fetch(`/api/follows/followers/${profileUserId}`, {
+        // The profile says whether the current user follows it
+        const response = await fetch(`/api/users/${profileUserId}`, {
             headers: {
                 'Authorization': `Bearer ${token}`
             }
@@ -30,8 +30,7 @@ async function checkFollowStatus(profileUserId) {
         
         const data = await response.json();
         
-        // Check if current user is in the followers list
-        return data.data.some(follow => follow.user.id === currentUser.id);
+        return !!data.data.you_follow;
     } catch (error) {
         console.error('Error checking follow status:', error);
         return false;
@@ -90,7 +90,8 @@ async function createPost(e) {
         const data = await response.json();
         
         if (!response.ok) {
-            throw new Error(data.error || data.message || `HTTP ${response.status}: ${response.statusText}`);
+            const fieldErrors = (data.errors || []).map(e => e.message).join(', ');
+            throw new Error(fieldErrors || data.error || data.message || `HTTP ${response.status}: ${response.statusText}`);
         }
         
         // Clear form
@@ -116,50 +116,29 @@ async function loadProfile() {
 
 async function loadFollowStats(userId) {
     try {
-        const followersResponse = await fetch(`/api/follows/followers/${userId}`, {
+        const response = await fetch(`/api/users/${userId}`, {
             headers: {
                 'Authorization': `Bearer ${token}`
             }
         });
         
-        let followersData = { data: [] };
-        if (followersResponse.ok) {
-            const followersText = await followersResponse.text();
-            if (followersText) {
-                followersData = JSON.parse(followersText);
-            }
-        }
-        
-        const followingResponse = await fetch(`/api/follows/following/${userId}`, {
-            headers: {
-                'Authorization': `Bearer ${token}`
-            }
-        });
-        
-        let followingData = { data: [] };
-        if (followingResponse.ok) {
-            const followingText = await followingResponse.text();
-            if (followingText) {
-                followingData = JSON.parse(followingText);
+        let profile = {};
+        if (response.ok) {
+            const text = await response.text();
+            if (text) {
+                profile = JSON.parse(text).data || {};
             }
         }
         
         const followersCountEl = document.getElementById('followers-count');
         const followingCountEl = document.getElementById('following-count');
         
-        const followersCount = followersData.data ? followersData.data.length : 0;
-        const followingCount = followingData.data ? followingData.data.length : 0;
-        
-        if (followersCountEl) followersCountEl.textContent = followersCount;
-        if (followingCountEl) followingCountEl.textContent = followingCount;
+        if (followersCountEl) followersCountEl.textContent = profile.follower_count || 0;
+        if (followingCountEl) followingCountEl.textContent = profile.following_count || 0;
         
         if (profileUserId !== currentUserId && currentUserId) {
-            const isFollowing = followersData.data && followersData.data.some(follow => 
-                follow.user && follow.user.id === currentUserId
-            );
-            
             if (typeof updateFollowButton === 'function') {
-                updateFollowButton(isFollowing);
+                updateFollowButton(!!profile.you_follow);
             }
         }
         